@@ -15,21 +15,22 @@
 package aide
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/99nil/gopkg/sets"
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/core"
+
+	"github.com/zc2638/aide/stage"
 )
 
 const APIVersion = "v1"
@@ -43,7 +44,25 @@ type Metadata struct {
 
 type Spec struct {
 	Prompts []SpecPrompt `json:"prompts" yaml:"prompts"`
-	Steps   []SpecStep   `json:"steps" yaml:"steps"`
+	// Vars defines the variables computed from the answers, labels, earlier vars and host facts.
+	Vars  []SpecVar  `json:"vars" yaml:"vars"`
+	Steps []SpecStep `json:"steps" yaml:"steps"`
+	// Inherit defines which environment variables of the host are inherited by the commands,
+	// all are inherited by default.
+	Inherit *EnvInherit `json:"inherit" yaml:"inherit"`
+	// FallbackLocale is used when the localized texts do not define the locale of the user,
+	// it defaults to DefaultFallbackLocale.
+	FallbackLocale string `json:"fallbackLocale" yaml:"fallbackLocale"`
+}
+
+// SpecVar defines a variable computed by a template expression, the vars are evaluated
+// in order after the prompts, and are available to the steps like the answers.
+type SpecVar struct {
+	Name string `json:"name" yaml:"name"`
+	// Value is a template evaluated as plain text without HTML escaping.
+	Value string `json:"value" yaml:"value"`
+	// Secret indicates that the value is sensitive, it is handled like the secret prompts.
+	Secret bool `json:"secret" yaml:"secret"`
 }
 
 type PromptType string
@@ -55,21 +74,126 @@ const (
 	PromptConfirm     PromptType = "Confirm"
 	PromptSelect      PromptType = "Select"
 	PromptMultiSelect PromptType = "MultiSelect"
+	// PromptNumber accepts an integer or a float in the range of Min and Max.
+	PromptNumber PromptType = "Number"
+	// PromptPath accepts a file path with autocompletion, and checks it by PathCheck.
+	PromptPath PromptType = "Path"
+	// PromptEditor opens $EDITOR to edit the answer.
+	PromptEditor PromptType = "Editor"
+	// PromptVersion accepts a semantic version.
+	PromptVersion PromptType = "Version"
 )
 
+var promptTypes = []PromptType{
+	PromptInput,
+	PromptText,
+	PromptPassword,
+	PromptConfirm,
+	PromptSelect,
+	PromptMultiSelect,
+	PromptNumber,
+	PromptPath,
+	PromptEditor,
+	PromptVersion,
+}
+
+// PathCheck defines the check of the answer of the Path prompt.
+type PathCheck string
+
+const (
+	// PathMustExist requires the path to exist.
+	PathMustExist PathCheck = "mustExist"
+	// PathMustNotExist requires the path not to exist.
+	PathMustNotExist PathCheck = "mustNotExist"
+	// PathCreatable requires the path to exist, or its nearest existing parent to be a directory.
+	PathCreatable PathCheck = "creatable"
+)
+
+var pathChecks = []PathCheck{PathMustExist, PathMustNotExist, PathCreatable}
+
 type SpecPrompt struct {
 	Name    string     `json:"name" yaml:"name"`
 	Type    PromptType `json:"type" yaml:"type"`
-	Message string     `json:"message" yaml:"message"`
+	Message LocaleText `json:"message" yaml:"message"`
 	Enum    []string   `json:"enum" yaml:"enum"`
 	Default string     `json:"default" yaml:"default"`
-	Help    string     `json:"help" yaml:"help"`
+	Help    LocaleText `json:"help" yaml:"help"`
+	// EnumLabels maps the options of Select and MultiSelect to the labels shown instead,
+	// the options are still stored as the answers.
+	EnumLabels map[string]LocaleText `json:"enumLabels" yaml:"enumLabels"`
+	// Secret indicates that the answer is sensitive, it is implied for Password.
+	// The secret answers are redacted in the logs, errors and command outputs,
+	// and are exported to the commands only if the step declares them in secrets.
+	Secret bool `json:"secret" yaml:"secret"`
+	// Min and Max define the range of the Number prompt, they are unlimited if nil.
+	Min *float64 `json:"min" yaml:"min"`
+	Max *float64 `json:"max" yaml:"max"`
+	// Integer indicates that the Number prompt only accepts integers.
+	Integer bool `json:"integer" yaml:"integer"`
+	// PathCheck defines the check of the Path prompt, the path is not checked if empty.
+	PathCheck PathCheck `json:"pathCheck" yaml:"pathCheck"`
+	// EnumFrom defines where to load the options of Select and MultiSelect
+	// just before the prompt is asked, they are appended to Enum.
+	EnumFrom *SpecEnumFrom `json:"enumFrom" yaml:"enumFrom"`
+
+	// labels defines the localized EnumLabels after resolved.
+	labels map[string]string
+}
+
+// SpecEnumFrom defines the source of the dynamic options, either the output of a command
+// or the content of a file, split by the separator.
+type SpecEnumFrom struct {
+	// Command is run by the shell with the earlier answers as environment variables.
+	Command string `json:"command" yaml:"command"`
+	// File is the path of the file, it can contain templates.
+	File string `json:"file" yaml:"file"`
+	// Separator splits the output into the options, it defaults to a newline.
+	// The options are trimmed, and the empty ones are dropped.
+	Separator string `json:"separator" yaml:"separator"`
+}
+
+// IsSecret returns whether the answer of the prompt is sensitive.
+func (p *SpecPrompt) IsSecret() bool {
+	return p.Secret || p.Type == PromptPassword
 }
 
 type SpecStep struct {
 	Name    string          `json:"name" yaml:"name"`
 	Render  *SpecStepRender `json:"render" yaml:"render"`
 	Command *string         `json:"command" yaml:"command"`
+	// Uses references the step type registered by RegisterStepType.
+	Uses string `json:"uses" yaml:"uses"`
+	// With defines the params of the step type, the string values can contain templates.
+	With map[string]interface{} `json:"with" yaml:"with"`
+	// Secrets defines the names of the secret prompts exported to the command or the step type.
+	Secrets []string `json:"secrets" yaml:"secrets"`
+	// Inherit overrides the spec.inherit of the pipeline for the command.
+	Inherit *EnvInherit `json:"inherit" yaml:"inherit"`
+	// Env defines the environment variables of the command, the values can contain templates.
+	Env map[string]string `json:"env" yaml:"env"`
+	// Script defines a multi-line script, it is run like the command.
+	Script string `json:"script" yaml:"script"`
+	// ScriptFile defines the path of the script file, the relative path is resolved like render.src.
+	ScriptFile string `json:"scriptFile" yaml:"scriptFile"`
+	// Shell runs the command or the script, e.g. sh, bash or python3 -c, it defaults to DefaultShell.
+	// A single word shell is run with -c, otherwise the script is appended to its args.
+	Shell string `json:"shell" yaml:"shell"`
+	// Strict runs sh and bash with set -eu (bash also with -o pipefail), it defaults to true.
+	Strict *bool `json:"strict" yaml:"strict"`
+	// Workdir defines the working directory of the command, it can contain templates
+	// and is created if missing.
+	Workdir string `json:"workdir" yaml:"workdir"`
+
+	// ForEach expands the step once per item, the item is available as {{ .item }}
+	// in the templates and as $AIDE_ITEM in the command.
+	ForEach *StepForEach `json:"forEach" yaml:"forEach"`
+	// Parallel defines the maximum number of the items run concurrently, they run in order by default.
+	Parallel int `json:"parallel" yaml:"parallel"`
+
+	// fsys defines the filesystem of ScriptFile, the local filesystem is used if nil.
+	fsys fs.FS
+	// outputs defines the outputs registered by the step type during execution.
+	outputs []string
 }
 
 type SpecStepRender struct {
@@ -77,6 +201,13 @@ type SpecStepRender struct {
 
 	Src  string `json:"src" yaml:"src"`
 	Dest string `json:"dest" yaml:"dest"`
+	// Include defines the globs of the files to be rendered in the src directory,
+	// all files will be rendered if it is empty.
+	Include []string `json:"include" yaml:"include"`
+	// Exclude defines the globs of the files or directories to be ignored in the src directory.
+	Exclude []string `json:"exclude" yaml:"exclude"`
+	// Raw defines the globs of the files to be copied byte-for-byte without templating.
+	Raw []string `json:"raw" yaml:"raw"`
 }
 
 func NewStepRender(src, dest string) *SpecStepRender {
@@ -87,6 +218,24 @@ func NewEmbedStepRender(fsys fs.FS, src, dest string) *SpecStepRender {
 	return &SpecStepRender{fsys: fsys, Src: src, Dest: dest}
 }
 
+// WithInclude sets the globs of the files to be rendered.
+func (r *SpecStepRender) WithInclude(patterns ...string) *SpecStepRender {
+	r.Include = patterns
+	return r
+}
+
+// WithExclude sets the globs of the files or directories to be ignored.
+func (r *SpecStepRender) WithExclude(patterns ...string) *SpecStepRender {
+	r.Exclude = patterns
+	return r
+}
+
+// WithRaw sets the globs of the files to be copied without templating.
+func (r *SpecStepRender) WithRaw(patterns ...string) *SpecStepRender {
+	r.Raw = patterns
+	return r
+}
+
 func NewPipeline(name string) *Pipeline {
 	return &Pipeline{
 		APIVersion: APIVersion,
@@ -99,6 +248,23 @@ func NewPipeline(name string) *Pipeline {
 
 type Pipeline struct {
 	skipPrompt bool
+	redactor   *strings.Replacer
+	stateDir   string
+	stateKey   string
+	pluginDir  string
+	lang       string
+	resume     bool
+	journal    *Journal
+	reporter   *reporter
+	report     *Report
+	logger     LogInterface
+	// showProgress indicates whether to render the progress on the terminal.
+	showProgress bool
+	progress     *progress
+	observers    []Observer
+	outputs      map[string]string
+	// mu guards the outputs registered by the steps running concurrently.
+	mu sync.Mutex
 
 	APIVersion string   `json:"apiVersion" yaml:"apiVersion"`
 	Kind       string   `json:"kind" yaml:"kind"`
@@ -106,18 +272,19 @@ type Pipeline struct {
 	Spec       Spec     `json:"spec" yaml:"spec"`
 }
 
-func (p *Pipeline) addPrompt(typ PromptType, name, message string, enum []string, defVal, help string) {
+func (p *Pipeline) addPrompt(typ PromptType, name, message string, enum []string, defVal, help string) *SpecPrompt {
 	prompt := SpecPrompt{
 		Name:    name,
 		Type:    typ,
-		Message: message,
+		Message: NewLocaleText(message),
 		Default: defVal,
-		Help:    help,
+		Help:    NewLocaleText(help),
 	}
 	if len(enum) > 0 {
 		prompt.Enum = enum
 	}
 	p.Spec.Prompts = append(p.Spec.Prompts, prompt)
+	return &p.Spec.Prompts[len(p.Spec.Prompts)-1]
 }
 
 func (p *Pipeline) AddInputPrompt(name, message string, defVal, helpVal string) {
@@ -145,6 +312,35 @@ func (p *Pipeline) AddMultiSelectPrompt(name, message string, enum []string, def
 	p.addPrompt(PromptMultiSelect, name, message, enum, strings.Join(defVal, ","), helpVal)
 }
 
+// AddNumberPrompt adds a Number prompt, min and max can be nil if unlimited.
+func (p *Pipeline) AddNumberPrompt(name, message string, defVal string, min, max *float64, helpVal string) {
+	prompt := p.addPrompt(PromptNumber, name, message, nil, defVal, helpVal)
+	prompt.Min, prompt.Max = min, max
+}
+
+// AddIntegerPrompt adds a Number prompt which only accepts integers, min and max can be nil if unlimited.
+func (p *Pipeline) AddIntegerPrompt(name, message string, defVal string, min, max *float64, helpVal string) {
+	prompt := p.addPrompt(PromptNumber, name, message, nil, defVal, helpVal)
+	prompt.Min, prompt.Max = min, max
+	prompt.Integer = true
+}
+
+// AddPathPrompt adds a Path prompt, the path is not checked if check is empty.
+func (p *Pipeline) AddPathPrompt(name, message string, defVal string, check PathCheck, helpVal string) {
+	prompt := p.addPrompt(PromptPath, name, message, nil, defVal, helpVal)
+	prompt.PathCheck = check
+}
+
+// AddEditorPrompt adds an Editor prompt which opens $EDITOR to edit the answer.
+func (p *Pipeline) AddEditorPrompt(name, message string, defVal, helpVal string) {
+	p.addPrompt(PromptEditor, name, message, nil, defVal, helpVal)
+}
+
+// AddVersionPrompt adds a Version prompt which accepts a semantic version.
+func (p *Pipeline) AddVersionPrompt(name, message string, defVal, helpVal string) {
+	p.addPrompt(PromptVersion, name, message, nil, defVal, helpVal)
+}
+
 func (p *Pipeline) AddStep(name string, render *SpecStepRender, command string) {
 	step := SpecStep{Name: name}
 	if render != nil {
@@ -156,251 +352,477 @@ func (p *Pipeline) AddStep(name string, render *SpecStepRender, command string)
 	p.Spec.Steps = append(p.Spec.Steps, step)
 }
 
+// AddScriptStep adds a step which runs the script by the shell, the shell defaults to DefaultShell if empty.
+func (p *Pipeline) AddScriptStep(name, shell, script string) {
+	p.Spec.Steps = append(p.Spec.Steps, SpecStep{Name: name, Shell: shell, Script: script})
+}
+
+// AddScriptFileStep adds a step which runs the script file in fsys by the shell,
+// the file is read from the local filesystem if fsys is nil.
+func (p *Pipeline) AddScriptFileStep(name, shell string, fsys fs.FS, file string) {
+	p.Spec.Steps = append(p.Spec.Steps, SpecStep{Name: name, Shell: shell, ScriptFile: file, fsys: fsys})
+}
+
+// AddVar adds a variable computed by the template expression value.
+func (p *Pipeline) AddVar(name, value string) {
+	p.Spec.Vars = append(p.Spec.Vars, SpecVar{Name: name, Value: value})
+}
+
+// AddUsesStep adds a step of the type registered by RegisterStepType or a plugin with the params.
+func (p *Pipeline) AddUsesStep(name, uses string, with map[string]interface{}) {
+	p.Spec.Steps = append(p.Spec.Steps, SpecStep{Name: name, Uses: uses, With: with})
+}
+
+// AddPipelineStep adds a step which runs the pipeline file in fsys non-interactively with the answers,
+// the file is read from the local filesystem if fsys is nil.
+func (p *Pipeline) AddPipelineStep(name string, fsys fs.FS, file string, answers map[string]string) {
+	with := map[string]interface{}{"file": file}
+	if len(answers) > 0 {
+		values := make(map[string]interface{}, len(answers))
+		for k, v := range answers {
+			values[k] = v
+		}
+		with["answers"] = values
+	}
+	p.Spec.Steps = append(p.Spec.Steps, SpecStep{Name: name, Uses: PipelineStepType, With: with, fsys: fsys})
+}
+
 func (p *Pipeline) BindFlags(set *flag.FlagSet) {
 	set.BoolVar(&p.skipPrompt, "skip-prompt", false, "Used to skip prompt interactions")
+	set.StringVar(&p.stateDir, "state-dir", p.stateDir, "The directory to persist the run state, empty to disable")
+	set.BoolVar(&p.resume, "resume", false, "Used to resume the last failed run, skipping the completed steps")
+	set.StringVar(&p.pluginDir, "plugin-dir", DefaultPluginDir(), "The directory to look up the plugins before PATH")
+	set.Var(&langFlag{p: p, set: set}, "lang", "The language of the prompts, e.g. en or zh_CN, defaults to LC_ALL or LANG")
+	locale, fallback := p.locale()
 	for k, prompt := range p.Spec.Prompts {
-		set.StringVar(&p.Spec.Prompts[k].Default, prompt.Name, prompt.Default, prompt.Help)
+		set.StringVar(&p.Spec.Prompts[k].Default, prompt.Name, prompt.Default, prompt.flagUsage(locale, fallback))
 	}
 }
 
 func (p *Pipeline) ParseFlags() {
+	// The lang is looked up in advance, so that the usages are localized even if it follows -help.
+	if lang, ok := langFromArgs(os.Args[1:]); ok {
+		p.SetLang(lang)
+	}
 	p.BindFlags(flag.CommandLine)
 	flag.Parse()
 }
 
+// Validate checks the pipeline and returns all the problems found as FieldErrors,
+// the lint warnings are not included.
 func (p *Pipeline) Validate() error {
-	if p.Kind != PipelineKind {
-		return fmt.Errorf("kind expect Pipeline, not %s", p.Kind)
+	errs := append(p.validate(), p.Lint().Errors()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *Pipeline) Execute(ctx context.Context) (err error) {
+	p.reporter = p.newReporter()
+	p.outputs = nil
+	defer func() {
+		p.report = p.reporter.finish(err)
+	}()
+
+	if err := p.startJournal(); err != nil {
+		return err
+	}
+	envSet, err := p.prepare(ctx)
+	if err != nil {
+		return err
 	}
-	if err := ValidateName(p.Metadata.Name); err != nil {
-		return fmt.Errorf("metadata.name validate failed: %v", err)
+	if err := p.recordAnswers(envSet); err != nil {
+		return fmt.Errorf("save state failed: %v", err)
 	}
-	if len(p.Spec.Steps) == 0 {
-		return errors.New("step is not define")
+	if err := p.executeSteps(ctx, envSet); err != nil {
+		return p.redactError(err)
 	}
-	for k, prompt := range p.Spec.Prompts {
-		if err := ValidateName(prompt.Name); err != nil {
-			return fmt.Errorf("prompt[%d].Name validate failed: %v", k, err)
-		}
-		switch prompt.Type {
-		case PromptInput:
-		case PromptPassword:
-		case PromptText:
-		case PromptConfirm:
-		case PromptSelect, PromptMultiSelect:
-			if len(prompt.Enum) == 0 {
-				return fmt.Errorf("prompt[%d].Enum validate failed: select requires at least one enum", k)
-			}
-		default:
-			return fmt.Errorf("prompt[%d].Type validate failed: unknow prompt type(%s)", k, prompt.Type)
+	p.outputs = make(map[string]string)
+	for k := range p.Spec.Steps {
+		for _, name := range p.Spec.Steps[k].Outputs() {
+			p.outputs[name] = envSet[name]
 		}
 	}
+	return p.finishJournal()
+}
 
-	for k, step := range p.Spec.Steps {
-		if step.Render == nil && step.Command == nil {
-			return errors.New("step render or command must be defined")
-		}
-		if step.Render != nil || len(step.Name) > 0 {
-			if err := ValidateName(step.Name); err != nil {
-				return fmt.Errorf("step[%d].Name validate failed: %v", k, err)
-			}
+// Outputs returns the outputs registered by the steps in the last successful execution.
+func (p *Pipeline) Outputs() map[string]string {
+	return p.outputs
+}
+
+// SetLogger sets the logger of the steps, the command output is written as logs.
+// By default, nothing is logged and the command output is written to stdout and stderr.
+func (p *Pipeline) SetLogger(logger LogInterface) {
+	p.logger = logger
+}
+
+// SetProgress renders the progress of the steps with spinners on the terminal,
+// it takes no effect if stdout is not a terminal or a logger is set.
+func (p *Pipeline) SetProgress(show bool) {
+	p.showProgress = show
+}
+
+// AddObserver registers the observer of the lifecycle events during Execute,
+// the steps of the pipeline are regarded as a single stage named by the pipeline.
+func (p *Pipeline) AddObserver(o Observer) {
+	p.observers = append(p.observers, o)
+}
+
+// Report returns the report of the last execution, it returns nil if it has not been executed.
+func (p *Pipeline) Report() *Report {
+	return p.report
+}
+
+// newReporter returns a reporter with the steps of the pipeline as a single stage.
+func (p *Pipeline) newReporter() *reporter {
+	p.progress = nil
+	observers := p.observers
+	if p.showProgress && p.logger == nil {
+		if p.progress = newProgress(os.Stdout); p.progress != nil {
+			observers = append([]Observer{p.progress}, observers...)
 		}
 	}
-	return nil
+	r := newReporter(p.Metadata.Name, observers...)
+	r.redact = p.redact
+	names := make([]string, 0, len(p.Spec.Steps))
+	for k := range p.Spec.Steps {
+		names = append(names, p.stepName(k))
+	}
+	r.addStage(p.Metadata.Name, names...)
+	return r
 }
 
-func (p *Pipeline) Execute(ctx context.Context) error {
-	envSet := make(map[string]string)
-
-	originEnv := os.Environ()
-	for _, env := range originEnv {
-		parts := strings.Split(env, "=")
-		envSet[parts[0]] = envSet[parts[1]]
+// stepName returns the name of the step, the unnamed steps are named by their indexes.
+func (p *Pipeline) stepName(k int) string {
+	if name := p.Spec.Steps[k].Name; len(name) > 0 {
+		return name
 	}
+	return fmt.Sprintf("step[%d]", k)
+}
+
+// stepLogger returns the logger bound to the run of the step, the pipeline is regarded as a stage.
+func (p *Pipeline) stepLogger(ctx context.Context, run stepRun) LogInterface {
+	sc := stage.NewCtx(ctx)
+	sc.WithValue(stage.NameKey, run.name)
+	sc.WithValue(StepCtxKey, &StepContext{
+		ctx:       sc,
+		stageName: p.Metadata.Name,
+		stepName:  run.name,
+		num:       run.num,
+	})
+	return withContext(p.logger, sc)
+}
 
+// prepare builds the variable set from the environment, the labels and the answers of the prompts.
+func (p *Pipeline) prepare(ctx context.Context) (map[string]string, error) {
+	// The variables of the host are not included, they are inherited by the commands by stepEnv.
+	envSet := make(map[string]string)
 	for k, v := range p.Metadata.Labels {
 		envSet[k] = v
 	}
 	if err := p.executePrompts(ctx, envSet); err != nil {
-		return err
+		return nil, err
 	}
-	return p.executeSteps(ctx, envSet)
-}
-
-func (p *Pipeline) executePrompts(_ context.Context, envSet map[string]string) error {
-	answers := make(map[string]interface{})
-	questions := make([]*survey.Question, 0, len(p.Spec.Prompts))
-	for _, v := range p.Spec.Prompts {
-		var prompt survey.Prompt
-		switch v.Type {
-		case PromptInput:
-			prompt = &survey.Input{Message: v.Message, Default: v.Default, Help: v.Help}
-		case PromptPassword:
-			prompt = &survey.Password{Message: v.Message, Help: v.Help}
-		case PromptText:
-			prompt = &survey.Multiline{Message: v.Message, Default: v.Default, Help: v.Help}
-		case PromptConfirm:
-			boolean, _ := strconv.ParseBool(v.Default)
-			prompt = &survey.Confirm{Message: v.Message, Default: boolean, Help: v.Help}
-		case PromptSelect:
-			if v.Default == "" && len(v.Enum) > 0 {
-				v.Default = v.Enum[0]
-			}
-			prompt = &survey.Select{
-				Message: v.Message,
-				Options: v.Enum,
-				Default: v.Default,
-				Help:    v.Help,
-			}
-		case PromptMultiSelect:
-			parts := strings.Split(v.Default, ",")
-			defSet := sets.NewString(parts...)
-			defSet = sets.NewString(v.Enum...).Intersection(defSet)
-			prompt = &survey.MultiSelect{
-				Message: v.Message,
-				Options: v.Enum,
-				Default: defSet.List(),
-				Help:    v.Help,
-			}
-		default:
-			continue
-		}
-		envSet[v.Name] = v.Default
-		questions = append(questions, &survey.Question{
-			Name:   v.Name,
-			Prompt: prompt,
-		})
-	}
-	if p.skipPrompt {
-		return nil
+	if err := p.evaluateVars(envSet); err != nil {
+		return nil, err
 	}
-	if err := survey.Ask(questions, &answers); err != nil {
-		return err
+	if p.journal != nil && p.resume {
+		for k, v := range p.journal.Outputs {
+			envSet[k] = v
+		}
 	}
+	p.buildRedactor(envSet)
+	return envSet, nil
+}
 
-	for k, answer := range answers {
-		switch val := answer.(type) {
-		case bool:
-			envSet[k] = strconv.FormatBool(val)
-		case string:
-			envSet[k] = val
-		case core.OptionAnswer:
-			envSet[k] = val.Value
-		case []core.OptionAnswer:
-			parts := make([]string, 0, len(val))
-			for _, v := range val {
-				parts = append(parts, v.Value)
+// SetValues sets the values of the prompts as their defaults,
+// it is usually used with the skip-prompt flag to answer the prompts non-interactively.
+func (p *Pipeline) SetValues(values map[string]string) error {
+	for name, value := range values {
+		found := false
+		for k := range p.Spec.Prompts {
+			if p.Spec.Prompts[k].Name == name {
+				p.Spec.Prompts[k].Default = value
+				found = true
+				break
 			}
-			envSet[k] = strings.Join(parts, ",")
+		}
+		if !found {
+			return fmt.Errorf("prompt %s is not defined", name)
 		}
 	}
 	return nil
 }
 
-func (p *Pipeline) executeSteps(ctx context.Context, envSet map[string]string) error {
-	var err error
-	for k, step := range p.Spec.Steps {
-		if step.Render != nil {
-			envSet[step.Name+"_src"] = step.Render.Src
-			envSet[step.Name+"_dest"] = step.Render.Src
-
-			var stat fs.FileInfo
-			if step.Render.fsys != nil {
-				stat, err = fs.Stat(step.Render.fsys, step.Render.Src)
-				if err != nil {
-					return fmt.Errorf("stat embed render[%d] src failed: %v", k, err)
-				}
-			} else {
-				stat, err = os.Stat(step.Render.Src)
-				if err != nil {
-					return fmt.Errorf("stat render[%d] src failed: %v", k, err)
-				}
+// executePrompts asks the prompts one at a time, the Message, Default, Help and Enum of each prompt
+// are evaluated as templates against the answers collected so far.
+func (p *Pipeline) executePrompts(ctx context.Context, envSet map[string]string) error {
+	locale, fallback := p.locale()
+	for k := range p.Spec.Prompts {
+		name := p.Spec.Prompts[k].Name
+		if answer, ok := p.answered(name); ok {
+			envSet[name] = answer
+			continue
+		}
+		v, err := p.Spec.Prompts[k].resolve(envSet, locale, fallback)
+		if err != nil {
+			return fmt.Errorf("evaluate spec.prompts[%d] %s failed: %v", k, name, err)
+		}
+		if v.EnumFrom != nil {
+			options, err := p.loadEnum(ctx, v.EnumFrom, envSet)
+			if err != nil {
+				return fmt.Errorf("load options of prompt %s failed: %v", name, err)
 			}
-			if !stat.IsDir() {
-				err = p.renderFile(envSet, step.Render.fsys, step.Render.Src, step.Render.Dest)
-			} else {
-				err = p.renderDir(envSet, step.Render.fsys, step.Render.Src, step.Render.Dest)
+			v.Enum = append(v.Enum, options...)
+			if len(v.Enum) == 0 {
+				return fmt.Errorf("prompt %s has no options", name)
 			}
+		}
+		prompt := v.surveyPrompt()
+		if prompt == nil {
+			continue
+		}
+		if p.skipPrompt {
+			value, err := v.normalize(v.Default)
 			if err != nil {
-				return fmt.Errorf("render[%d] failed: %v", k, err)
+				return fmt.Errorf("invalid value of prompt %s: %v", v.Name, err)
 			}
+			envSet[v.Name] = value
+			continue
 		}
-		if step.Command != nil {
-			cmd := exec.CommandContext(ctx, "/bin/sh", "-c", *step.Command)
-			cmd.Env = envToSlice(envSet)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("run command (%s) failed: %v", *step.Command, err)
+
+		answers := make(map[string]interface{})
+		questions := []*survey.Question{{
+			Name:   v.Name,
+			Prompt: prompt,
+			Validate: func(ans interface{}) error {
+				_, err := v.normalize(v.answerString(ans))
+				return err
+			},
+		}}
+		if err := survey.Ask(questions, &answers); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("ask prompt %s failed: stdin is closed, use --skip-prompt to answer with the defaults", v.Name)
 			}
+			return err
 		}
+		envSet[v.Name], _ = v.normalize(v.answerString(answers[v.Name]))
 	}
 	return nil
 }
 
-func (p *Pipeline) renderDir(envSet map[string]string, fsys fs.FS, src, dest string) error {
-	err := os.MkdirAll(dest, fs.ModePerm)
+// resolve returns a copy of the prompt with the Message, Help and EnumLabels localized,
+// and the Message, Default, Help and Enum evaluated as templates against envSet.
+// The enum items evaluated as empty are dropped.
+func (v *SpecPrompt) resolve(envSet map[string]string, locale, fallback string) (*SpecPrompt, error) {
+	out := *v
+	message, err := renderPromptField(envSet, "message", v.Message.Localize(locale, fallback))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	help, err := renderPromptField(envSet, "help", v.Help.Localize(locale, fallback))
+	if err != nil {
+		return nil, err
+	}
+	out.Message, out.Help = NewLocaleText(message), NewLocaleText(help)
+	if out.Default, err = renderPromptField(envSet, "default", v.Default); err != nil {
+		return nil, err
+	}
+	if len(v.EnumLabels) > 0 {
+		out.labels = make(map[string]string, len(v.EnumLabels))
+		for value, label := range v.EnumLabels {
+			out.labels[value] = label.Localize(locale, fallback)
+		}
+	}
+	if len(v.Enum) > 0 {
+		out.Enum = make([]string, 0, len(v.Enum))
+		for i, item := range v.Enum {
+			value, err := renderPromptField(envSet, fmt.Sprintf("enum[%d]", i), item)
+			if err != nil {
+				return nil, err
+			}
+			if len(value) > 0 {
+				out.Enum = append(out.Enum, value)
+			}
+		}
 	}
+	return &out, nil
+}
 
-	var dir []fs.DirEntry
-	if fsys != nil {
-		dir, err = fs.ReadDir(fsys, src)
-	} else {
-		dir, err = os.ReadDir(src)
+// renderPromptField renders the field of the prompt if it contains templates.
+func renderPromptField(envSet map[string]string, field, text string) (string, error) {
+	if !isTemplate(text) {
+		return text, nil
 	}
+	value, err := renderText(envSet, field, text)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("%s: %v", field, err)
 	}
+	return value, nil
+}
 
-	for _, e := range dir {
-		if !e.IsDir() {
-			if err := p.renderFile(envSet, fsys, src, dest); err != nil {
-				return err
+// surveyPrompt returns the survey prompt of the prompt, it returns nil for the unknown types.
+// For Select, the first item of the enum is used as the default if the default is empty.
+func (v *SpecPrompt) surveyPrompt() survey.Prompt {
+	switch v.Type {
+	case PromptInput:
+		return &survey.Input{Message: v.Message.Text, Default: v.Default, Help: v.Help.Text}
+	case PromptPassword:
+		return &survey.Password{Message: v.Message.Text, Help: v.Help.Text}
+	case PromptText:
+		return &survey.Multiline{Message: v.Message.Text, Default: v.Default, Help: v.Help.Text}
+	case PromptConfirm:
+		boolean, _ := strconv.ParseBool(v.Default)
+		return &survey.Confirm{Message: v.Message.Text, Default: boolean, Help: v.Help.Text}
+	case PromptSelect:
+		if v.Default == "" && len(v.Enum) > 0 {
+			v.Default = v.Enum[0]
+		}
+		var defLabel interface{}
+		if containsString(v.Enum, v.Default) {
+			defLabel = v.label(v.Default)
+		}
+		return &survey.Select{
+			Message: v.Message.Text,
+			Options: v.options(),
+			Default: defLabel,
+			Help:    v.Help.Text,
+		}
+	case PromptMultiSelect:
+		defSet := sets.NewString(strings.Split(v.Default, ",")...)
+		var defLabels []string
+		for _, item := range v.Enum {
+			if defSet.Has(item) {
+				defLabels = append(defLabels, v.label(item))
 			}
-			continue
 		}
-
-		currentSrc := filepath.Join(src, e.Name())
-		currentDest := filepath.Join(dest, e.Name())
-		if err := p.renderDir(envSet, fsys, currentSrc, currentDest); err != nil {
-			return err
+		return &survey.MultiSelect{
+			Message: v.Message.Text,
+			Options: v.options(),
+			Default: defLabels,
+			Help:    v.Help.Text,
 		}
+	case PromptNumber, PromptVersion:
+		return &survey.Input{Message: v.Message.Text, Default: v.Default, Help: v.Help.Text}
+	case PromptPath:
+		return &survey.Input{Message: v.Message.Text, Default: v.Default, Help: v.Help.Text, Suggest: suggestPaths}
+	case PromptEditor:
+		return &survey.Editor{Message: v.Message.Text, Default: v.Default, Help: v.Help.Text, AppendDefault: true, HideDefault: true}
 	}
 	return nil
 }
 
-func (p *Pipeline) renderFile(envSet map[string]string, fsys fs.FS, src, dest string) error {
-	dir := filepath.Dir(dest)
-	_, err := os.Stat(dir)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
+// options returns the labels of the enum shown by Select and MultiSelect.
+func (v *SpecPrompt) options() []string {
+	options := make([]string, 0, len(v.Enum))
+	for _, item := range v.Enum {
+		options = append(options, v.label(item))
+	}
+	return options
+}
+
+// label returns the localized label of the option, or the option itself if no label is defined.
+func (v *SpecPrompt) label(option string) string {
+	if label := v.labels[option]; len(label) > 0 {
+		return label
+	}
+	return option
+}
+
+// answerString converts the answer of survey to the value of the variable,
+// the options are converted from the labels by index, and those of MultiSelect are joined by comma.
+func (v *SpecPrompt) answerString(answer interface{}) string {
+	option := func(ans core.OptionAnswer) string {
+		if ans.Index >= 0 && ans.Index < len(v.Enum) {
+			return v.Enum[ans.Index]
 		}
-		if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
-			return err
+		return ans.Value
+	}
+	switch val := answer.(type) {
+	case bool:
+		return strconv.FormatBool(val)
+	case string:
+		return val
+	case core.OptionAnswer:
+		return option(val)
+	case []core.OptionAnswer:
+		parts := make([]string, 0, len(val))
+		for _, ans := range val {
+			parts = append(parts, option(ans))
 		}
+		return strings.Join(parts, ",")
 	}
+	return ""
+}
 
-	var b []byte
-	if fsys != nil {
-		b, err = fs.ReadFile(fsys, src)
-	} else {
-		b, err = os.ReadFile(src)
-	}
+func (p *Pipeline) executeSteps(ctx context.Context, envSet map[string]string) error {
+	runs, err := p.stepRuns(envSet)
 	if err != nil {
 		return err
 	}
+	var names []string
+	for _, stepRuns := range runs {
+		for _, run := range stepRuns {
+			names = append(names, run.name)
+		}
+	}
+	p.reporter.setSteps(0, names...)
 
-	t, err := tpl.Parse(string(b))
-	if err != nil {
-		return fmt.Errorf("parse template(%s) failed: %v", src, err)
+	p.reporter.startStage(0)
+	for k := range p.Spec.Steps {
+		if p.completed(k) {
+			for _, run := range runs[k] {
+				p.reporter.skipStep(0, run.num)
+			}
+			continue
+		}
+		if err := p.executeRuns(ctx, envSet, k, runs[k]); err != nil {
+			return err
+		}
+		if err := p.recordStep(k, envSet); err != nil {
+			return fmt.Errorf("save state failed: %v", err)
+		}
 	}
+	p.reporter.finishStage(0, nil)
+	return nil
+}
 
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, map[string]interface{}{"env": envSet}); err != nil {
-		return err
+func (p *Pipeline) executeStep(ctx context.Context, envSet map[string]string, run stepRun) error {
+	step := p.Spec.Steps[run.index]
+	if step.Render != nil {
+		if err := p.renderStep(envSet, run.index, ""); err != nil {
+			return err
+		}
+	}
+	if len(step.Uses) > 0 {
+		if err := p.executeUses(ctx, envSet, run); err != nil {
+			return err
+		}
+	}
+	if step.runsScript() {
+		var outWriter, errWriter io.Writer = os.Stdout, os.Stderr
+		if p.logger != nil {
+			logger := p.stepLogger(ctx, run)
+			outLog := &lineWriter{log: func(line string) { logger.Log(InfoLevel, line) }}
+			errLog := &lineWriter{log: func(line string) { logger.Log(WarnLevel, line) }}
+			defer outLog.Flush()
+			defer errLog.Flush()
+			outWriter, errWriter = outLog, errLog
+		} else if p.progress != nil {
+			tail := p.progress.tailWriter(run.num)
+			defer tail.Flush()
+			outWriter, errWriter = tail, tail
+		}
+
+		stdout, stderr := p.newMaskWriter(outWriter), p.newMaskWriter(errWriter)
+		err := p.runScript(ctx, envSet, &step, stdout, stderr)
+		_ = stdout.Flush()
+		_ = stderr.Flush()
+		if err != nil {
+			return err
+		}
 	}
-	return os.WriteFile(dest, buf.Bytes(), fs.ModePerm)
+	return nil
 }
@@ -15,11 +15,11 @@
 package app
 
 import (
-	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 
 	"github.com/zc2638/aide"
 )
@@ -28,39 +28,94 @@ func NewRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "aide",
 	}
-	cmd.AddCommand(NewApplyCmd())
+	cmd.AddCommand(
+		NewApplyCmd(),
+		NewSchemaCmd(),
+		NewValidateCmd(),
+		NewLintCmd(),
+		NewBuildCmd(),
+		NewInitCmd(),
+		NewRenderCmd(),
+	)
 	return cmd
 }
 
 type ApplyOption struct {
-	Path string
+	Source
+	Values Values
+
+	// Report is the format of the run report, json or junit.
+	Report     string
+	ReportFile string
+	// LogFormat is the format of the logs, text or json.
+	LogFormat string
+	// Progress renders the progress of the steps on the terminal.
+	Progress bool
 }
 
 func NewApplyCmd() *cobra.Command {
 	opt := &ApplyOption{}
 	cmd := &cobra.Command{
-		Use:          "apply",
-		SilenceUsage: true,
+		Use:                "apply",
+		SilenceUsage:       true,
+		DisableFlagParsing: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(opt.Path) == 0 {
-				return errors.New("please specify the configuration file to execute")
-			}
-			filedata, err := os.ReadFile(opt.Path)
-			if err != nil {
-				return err
-			}
-			var pipeline aide.Pipeline
-			if err := yaml.Unmarshal(filedata, &pipeline); err != nil {
+			pipeline, err := opt.LoadWithFlags(cmd, args, &opt.Values)
+			if err != nil || pipeline == nil {
 				return err
 			}
 
-			pipeline.ParseFlags()
+			switch opt.LogFormat {
+			case "", "text":
+				pipeline.SetProgress(opt.Progress)
+			case "json":
+				pipeline.SetLogger(aide.NewJSONLog(cmd.ErrOrStderr()))
+			default:
+				return fmt.Errorf("unknown log format %q, expect text or json", opt.LogFormat)
+			}
 			if err := pipeline.Validate(); err != nil {
 				return err
 			}
-			return pipeline.Execute(cmd.Context())
+			err = pipeline.Execute(cmd.Context())
+			if reportErr := writeReport(cmd, opt.Report, opt.ReportFile, pipeline.Report()); reportErr != nil && err == nil {
+				err = reportErr
+			}
+			return err
 		},
 	}
-	cmd.Flags().StringVarP(&opt.Path, "file", "f", opt.Path, "that contains the configuration to apply")
+	opt.Source.AddFlags(cmd, "that contains the configuration to apply, \"-\" for stdin or a http(s) URL")
+	opt.Values.AddFlags(cmd)
+	cmd.Flags().StringVar(&opt.Report, "report", opt.Report, "the format of the run report, json or junit")
+	cmd.Flags().StringVar(&opt.ReportFile, "report-file", opt.ReportFile, "the file to write the run report to, defaults to stdout")
+	cmd.Flags().StringVar(&opt.LogFormat, "log-format", "text", "the format of the logs, text or json")
+	cmd.Flags().BoolVar(&opt.Progress, "progress", true, "render the progress of the steps on the terminal, the plain output is used if stdout is not a terminal")
 	return cmd
 }
+
+func writeReport(cmd *cobra.Command, format, file string, report *aide.Report) error {
+	if len(format) == 0 || report == nil {
+		return nil
+	}
+	var write func(w io.Writer) error
+	switch format {
+	case "json":
+		write = report.WriteJSON
+	case "junit":
+		write = report.WriteJUnit
+	default:
+		return fmt.Errorf("unknown report format %q, expect json or junit", format)
+	}
+
+	if len(file) == 0 || file == "-" {
+		return write(cmd.OutOrStdout())
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
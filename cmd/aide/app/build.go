@@ -0,0 +1,346 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zc2638/aide"
+)
+
+const aideModule = "github.com/zc2638/aide"
+
+// buildResourceDir is the directory in the generated module where the pipeline
+// and its render sources are embedded.
+const buildResourceDir = "resource"
+
+var buildMainTemplate = template.Must(template.New("main.go").Parse(`// Code generated by aide build. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	"embed"
+	"log"
+	"os"
+
+	"github.com/zc2638/aide"
+)
+
+//go:embed all:{{ .ResourceDir }}
+var embedFS embed.FS
+
+func main() {
+	pipeline, err := aide.LoadPipeline(embedFS, {{ printf "%q" .PipelinePath }})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The run state of the installer is kept apart by the path of the executable.
+	if exe, err := os.Executable(); err == nil {
+		pipeline.SetStateKey(exe)
+	}
+	pipeline.SetStateDir(aide.DefaultStateDir())
+	pipeline.ParseFlags()
+	if err := pipeline.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := pipeline.Execute(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+`))
+
+var buildModTemplate = template.Must(template.New("go.mod").Parse(`module {{ .Module }}
+
+go 1.18
+
+require {{ .AideModule }} {{ .AideVersion }}
+{{- if .AidePath }}
+
+replace {{ .AideModule }} => {{ printf "%q" .AidePath }}
+{{- end }}
+`))
+
+type BuildOption struct {
+	Source
+
+	Output string
+	GOOS   string
+	GOARCH string
+	// AidePath is the local source directory of aide, used instead of the released module.
+	AidePath string
+}
+
+func NewBuildCmd() *cobra.Command {
+	opt := &BuildOption{
+		GOOS:   envOr("GOOS", runtime.GOOS),
+		GOARCH: envOr("GOARCH", runtime.GOARCH),
+	}
+	cmd := &cobra.Command{
+		Use:          "build",
+		Short:        "Compile the pipeline into a standalone installer binary",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opt.Run(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	opt.Source.AddFlags(cmd, "that contains the configuration to build, \"-\" for stdin or a http(s) URL")
+	cmd.Flags().StringVarP(&opt.Output, "output", "o", opt.Output, "the output file of the installer, defaults to the pipeline name")
+	cmd.Flags().StringVar(&opt.GOOS, "os", opt.GOOS, "the target operating system, defaults to $GOOS or the host")
+	cmd.Flags().StringVar(&opt.GOARCH, "arch", opt.GOARCH, "the target architecture, defaults to $GOARCH or the host")
+	cmd.Flags().StringVar(&opt.AidePath, "aide-path", opt.AidePath, "the local source directory of aide to build with")
+	return cmd
+}
+
+func (o *BuildOption) Run(ctx context.Context, out io.Writer) error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return errors.New("the go toolchain is required to build the installer")
+	}
+	aideVersion, aidePath, err := o.aideModule()
+	if err != nil {
+		return err
+	}
+
+	ps, err := o.read(ctx)
+	if err != nil {
+		return err
+	}
+	workDir, err := os.MkdirTemp("", "aide-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	pipeline, err := generateModule(ps, workDir, aideVersion, aidePath)
+	if err != nil {
+		return err
+	}
+
+	output := o.Output
+	if len(output) == 0 {
+		output = pipeline.Metadata.Name
+		if o.GOOS == "windows" {
+			output += ".exe"
+		}
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), "GOOS="+o.GOOS, "GOARCH="+o.GOARCH, "CGO_ENABLED=0")
+	// Resolve the missing requirements while building,
+	// instead of tidying which requires the test dependencies too.
+	args := []string{"build", "-mod=mod", "-trimpath", "-ldflags", "-s -w", "-o", output, "."}
+	c := exec.CommandContext(ctx, "go", args...)
+	c.Dir = workDir
+	c.Env = env
+	c.Stdout = out
+	c.Stderr = out
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("run go %s failed: %v", strings.Join(args, " "), err)
+	}
+	fmt.Fprintf(out, "Installer built at %s (%s/%s)\n", output, o.GOOS, o.GOARCH)
+	return nil
+}
+
+// generateModule writes the module of the installer into workDir, the pipeline is embedded
+// with its render sources, script files and nested pipelines.
+func generateModule(ps *pipelineSource, workDir, aideVersion, aidePath string) (*aide.Pipeline, error) {
+	if errs := aide.ValidatePipeline(ps.data, ps.fsys, ps.dir).Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid pipeline %s:\n%v", ps.name, errs)
+	}
+	pipeline, err := aide.ParsePipeline(ps.data, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resourceDir := filepath.Join(workDir, buildResourceDir)
+	if err := os.MkdirAll(resourceDir, 0o755); err != nil {
+		return nil, err
+	}
+	for k := range pipeline.Spec.Steps {
+		step := &pipeline.Spec.Steps[k]
+		if step.Render != nil {
+			src, err := embedSource(ps, step.Render.Src, resourceDir, k)
+			if err != nil {
+				return nil, fmt.Errorf("embed render[%d] src failed: %v", k, err)
+			}
+			step.Render.Src = src
+		}
+		if len(step.ScriptFile) > 0 {
+			file, err := embedSource(ps, step.ScriptFile, resourceDir, k)
+			if err != nil {
+				return nil, fmt.Errorf("embed steps[%d] scriptFile failed: %v", k, err)
+			}
+			step.ScriptFile = file
+		}
+		// The directory of the nested pipeline is embedded with its resources.
+		if file, ok := step.With["file"].(string); ok && step.Uses == aide.PipelineStepType && !strings.Contains(file, "{{") {
+			dir, err := embedSource(ps, filepath.Dir(file), resourceDir, k)
+			if err != nil {
+				return nil, fmt.Errorf("embed steps[%d] pipeline failed: %v", k, err)
+			}
+			step.With["file"] = path.Join(dir, filepath.Base(file))
+		}
+	}
+	data, err := yaml.Marshal(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(resourceDir, "pipeline.yaml"), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	files := map[string]*template.Template{
+		"main.go": buildMainTemplate,
+		"go.mod":  buildModTemplate,
+	}
+	values := map[string]interface{}{
+		"Module":       "aide-installer/" + pipeline.Metadata.Name,
+		"ResourceDir":  buildResourceDir,
+		"PipelinePath": path.Join(buildResourceDir, "pipeline.yaml"),
+		"AideModule":   aideModule,
+		"AideVersion":  aideVersion,
+		"AidePath":     aidePath,
+	}
+	for name, t := range files {
+		f, err := os.Create(filepath.Join(workDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Execute(f, values); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return pipeline, nil
+}
+
+// envOr returns the environment variable name, or def if it is empty.
+func envOr(name, def string) string {
+	if value := os.Getenv(name); len(value) > 0 {
+		return value
+	}
+	return def
+}
+
+// aideModule returns the version of aide required by the installer, and the local
+// source directory to replace it with if specified.
+func (o *BuildOption) aideModule() (string, string, error) {
+	if len(o.AidePath) > 0 {
+		p, err := filepath.Abs(o.AidePath)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err := os.Stat(filepath.Join(p, "go.mod")); err != nil {
+			return "", "", fmt.Errorf("aide path %s is not a go module: %v", p, err)
+		}
+		return "v0.0.0", p, nil
+	}
+
+	if version := releasedAideVersion(); len(version) > 0 {
+		return version, "", nil
+	}
+	return "", "", errors.New("unable to detect the released version of aide, please specify --aide-path")
+}
+
+// pseudoVersionRE matches the pseudo-versions stamped on the builds from a VCS checkout,
+// it is the same as the one in golang.org/x/mod/module.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// releasedAideVersion returns the released version of the running aide,
+// it returns empty if aide is built from source.
+func releasedAideVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if ok && info.Main.Path == aideModule && isReleasedVersion(info.Main.Version) {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// isReleasedVersion reports whether version is a published version of the module,
+// the pseudo-versions and the versions with build metadata, e.g. +dirty, are not published.
+func isReleasedVersion(version string) bool {
+	if version == "" || version == "(devel)" {
+		return false
+	}
+	return !strings.Contains(version, "+") && !pseudoVersionRE.MatchString(version)
+}
+
+// embedSource copies the render source, the script file or the directory of the nested pipeline
+// into the resource directory, and returns the source path relative to the embedded pipeline.
+// The sources outside the pipeline directory are placed in a dedicated directory for the step.
+func embedSource(ps *pipelineSource, src, resourceDir string, index int) (string, error) {
+	var (
+		fsys fs.FS
+		name string
+	)
+	switch {
+	case ps.fsys != nil && !filepath.IsAbs(src):
+		fsys, name = ps.fsys, path.Join(ps.dir, filepath.ToSlash(src))
+	case filepath.IsAbs(src):
+		fsys, name = os.DirFS(filepath.Dir(src)), filepath.Base(src)
+	default:
+		local := filepath.Join(ps.dir, src)
+		fsys, name = os.DirFS(filepath.Dir(local)), filepath.Base(local)
+	}
+
+	target := path.Clean(filepath.ToSlash(src))
+	if filepath.IsAbs(src) || target == ".." || strings.HasPrefix(target, "../") {
+		target = path.Join(".aide", strconv.Itoa(index), path.Base(target))
+	}
+
+	destRoot := filepath.Join(resourceDir, filepath.FromSlash(target))
+	err := fs.WalkDir(fsys, name, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(current, name), "/")
+		dest := filepath.Join(destRoot, filepath.FromSlash(rel))
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0o755)
+		}
+		b, err := fs.ReadFile(fsys, current)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, b, 0o644)
+	})
+	return target, err
+}
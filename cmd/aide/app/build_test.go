@@ -0,0 +1,167 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/zc2638/aide"
+)
+
+func TestEmbedSource(t *testing.T) {
+	ps := &pipelineSource{
+		name: "res/pipeline.yaml",
+		fsys: fstest.MapFS{
+			"res/tmpl/a.in":       {Data: []byte("a")},
+			"res/conf/b.conf":     {Data: []byte("b")},
+			"res/conf/sub/c.conf": {Data: []byte("c")},
+			"shared/install.sh":   {Data: []byte("true")},
+		},
+		dir: "res",
+	}
+	tests := []struct {
+		src   string
+		want  string
+		files []string
+	}{
+		{src: "tmpl/a.in", want: "tmpl/a.in", files: []string{"tmpl/a.in"}},
+		{src: "conf", want: "conf", files: []string{"conf/b.conf", "conf/sub/c.conf"}},
+		{src: "../shared/install.sh", want: ".aide/2/install.sh", files: []string{".aide/2/install.sh"}},
+	}
+	resourceDir := t.TempDir()
+	for k, tt := range tests {
+		got, err := embedSource(ps, tt.src, resourceDir, k)
+		if err != nil {
+			t.Fatalf("embedSource(%s) error = %v", tt.src, err)
+		}
+		if got != tt.want {
+			t.Errorf("embedSource(%s) = %s, want %s", tt.src, got, tt.want)
+		}
+		for _, name := range tt.files {
+			if _, err := os.Stat(filepath.Join(resourceDir, filepath.FromSlash(name))); err != nil {
+				t.Errorf("embedSource(%s) file %s error = %v", tt.src, name, err)
+			}
+		}
+	}
+}
+
+func TestGenerateModule(t *testing.T) {
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: demo
+spec:
+  prompts:
+    - name: dir
+      type: Path
+      default: /opt/demo
+  steps:
+    - name: config
+      render:
+        src: tmpl/config.in
+        dest: "{{ .env.dir }}/config"
+    - name: install
+      scriptFile: ../scripts/install.sh
+    - command: echo done
+`
+	ps := &pipelineSource{
+		name: "res/pipeline.yaml",
+		data: []byte(data),
+		fsys: fstest.MapFS{
+			"res/tmpl/config.in": {Data: []byte("dir={{ .env.dir }}")},
+			"scripts/install.sh": {Data: []byte("true")},
+		},
+		dir: "res",
+	}
+	workDir := t.TempDir()
+	pipeline, err := generateModule(ps, workDir, "v0.0.0", "/src/aide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.Metadata.Name != "demo" {
+		t.Errorf("pipeline name = %s", pipeline.Metadata.Name)
+	}
+
+	// The embedded pipeline is loaded by the installer the same way.
+	embedded, err := aide.LoadPipeline(os.DirFS(workDir), "resource/pipeline.yaml")
+	if err != nil {
+		t.Fatalf("load the embedded pipeline error = %v", err)
+	}
+	if err := embedded.Validate(); err != nil {
+		t.Fatalf("Validate() the embedded pipeline error = %v", err)
+	}
+	if got := embedded.Spec.Steps[1].ScriptFile; got != "resource/.aide/1/install.sh" {
+		t.Errorf("embedded scriptFile = %s", got)
+	}
+
+	b, err := os.ReadFile(filepath.Join(workDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `replace github.com/zc2638/aide => "/src/aide"`) {
+		t.Errorf("go.mod = %s", b)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), filepath.Join(workDir, "main.go"), nil, 0); err != nil {
+		t.Errorf("parse main.go error = %v", err)
+	}
+
+	// The pipeline without any file to embed.
+	ps = &pipelineSource{name: "<stdin>", data: []byte(strings.Split(data, "    - name: config")[0] + "    - command: echo done\n")}
+	if _, err := generateModule(ps, t.TempDir(), "v0.0.0", ""); err != nil {
+		t.Errorf("generateModule() without files error = %v", err)
+	}
+}
+
+func TestNewBuildCmd(t *testing.T) {
+	t.Setenv("GOOS", "windows")
+	t.Setenv("GOARCH", "arm64")
+	cmd := NewBuildCmd()
+	if got := cmd.Flags().Lookup("os").DefValue; got != "windows" {
+		t.Errorf("default os = %s, want windows", got)
+	}
+	if got := cmd.Flags().Lookup("arch").DefValue; got != "arm64" {
+		t.Errorf("default arch = %s, want arm64", got)
+	}
+}
+
+func TestIsReleasedVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "v1.2.3", want: true},
+		{version: "v1.2.3-rc.1", want: true},
+		{version: "", want: false},
+		{version: "(devel)", want: false},
+		{version: "v0.0.0-20261019080951-f48b12ccbd13", want: false},
+		{version: "v1.2.4-0.20261019080951-f48b12ccbd13", want: false},
+		{version: "v1.2.3-rc.1.0.20261019080951-f48b12ccbd13", want: false},
+		{version: "v0.0.0-20261019080951-f48b12ccbd13+dirty", want: false},
+		{version: "v1.2.3+dirty", want: false},
+		{version: "v2.0.0+incompatible", want: false},
+	}
+	for _, tt := range tests {
+		if got := isReleasedVersion(tt.version); got != tt.want {
+			t.Errorf("isReleasedVersion(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/zc2638/aide"
+)
+
+//go:embed all:templates
+var templateFS embed.FS
+
+type InitOption struct {
+	Dir    string
+	Name   string
+	Module string
+	Go     bool
+	Yes    bool
+	Force  bool
+}
+
+func NewInitCmd() *cobra.Command {
+	opt := &InitOption{Dir: "."}
+	cmd := &cobra.Command{
+		Use:          "init",
+		Short:        "Scaffold a new pipeline or Go installer",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.ask(); err != nil {
+				return err
+			}
+			return opt.Run(cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVarP(&opt.Dir, "dir", "d", opt.Dir, "the directory to write to")
+	cmd.Flags().StringVar(&opt.Name, "name", opt.Name, "the name of the pipeline or installer")
+	cmd.Flags().StringVar(&opt.Module, "module", opt.Module, "the Go module path, only for --go")
+	cmd.Flags().BoolVar(&opt.Go, "go", opt.Go, "scaffold a Go installer instead of a pipeline.yaml")
+	cmd.Flags().BoolVarP(&opt.Yes, "yes", "y", opt.Yes, "use the defaults without asking")
+	cmd.Flags().BoolVar(&opt.Force, "force", opt.Force, "overwrite the existing files")
+	return cmd
+}
+
+// ask asks for the options which are not specified by flags.
+func (o *InitOption) ask() error {
+	if len(o.Name) == 0 {
+		abs, err := filepath.Abs(o.Dir)
+		if err != nil {
+			return err
+		}
+		o.Name = strings.ToLower(filepath.Base(abs))
+	}
+	if o.Yes {
+		return nil
+	}
+
+	questions := []*survey.Question{
+		{
+			Name:     "Name",
+			Prompt:   &survey.Input{Message: "What's the name of the installer?", Default: o.Name},
+			Validate: func(ans interface{}) error { return aide.ValidateName(ans.(string)) },
+		},
+		{
+			Name: "Go",
+			Prompt: &survey.Confirm{
+				Message: "Scaffold a Go installer instead of a pipeline.yaml?",
+				Default: o.Go,
+			},
+		},
+	}
+	if err := survey.Ask(questions, o); err != nil {
+		return err
+	}
+	if o.Go && len(o.Module) == 0 {
+		return survey.AskOne(&survey.Input{
+			Message: "What's the Go module path?",
+			Default: "example.com/" + o.Name,
+		}, &o.Module, survey.WithValidator(survey.Required))
+	}
+	return nil
+}
+
+func (o *InitOption) Run(out io.Writer) error {
+	if err := aide.ValidateName(o.Name); err != nil {
+		return fmt.Errorf("invalid name %q: %v", o.Name, err)
+	}
+	root := "templates/pipeline"
+	if o.Go {
+		root = "templates/go"
+		if len(o.Module) == 0 {
+			o.Module = "example.com/" + o.Name
+		}
+	}
+	values := map[string]string{
+		"Name":        o.Name,
+		"Module":      o.Module,
+		"AideVersion": releasedAideVersion(),
+	}
+
+	files := make(map[string][]byte)
+	err := fs.WalkDir(templateFS, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(templateFS, name)
+		if err != nil {
+			return err
+		}
+		// The scaffolds are templates themselves, so use different delimiters.
+		t, err := template.New(name).Delims("[[", "]]").Parse(string(b))
+		if err != nil {
+			return err
+		}
+		var buf strings.Builder
+		if err := t.Execute(&buf, values); err != nil {
+			return err
+		}
+		rel := strings.TrimSuffix(strings.TrimPrefix(name, root+"/"), ".tmpl")
+		files[rel] = []byte(buf.String())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !o.Go {
+		schema, err := json.MarshalIndent(aide.PipelineSchema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		files["pipeline.schema.json"] = append(schema, '\n')
+	}
+
+	if !o.Force {
+		for name := range files {
+			if _, err := os.Stat(filepath.Join(o.Dir, filepath.FromSlash(name))); err == nil {
+				return fmt.Errorf("%s already exists, use --force to overwrite", path.Join(o.Dir, name))
+			}
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := files[name]
+		dest := filepath.Join(o.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "create %s\n", dest)
+	}
+
+	if o.Go {
+		fmt.Fprintf(out, "\nRun `cd %s && go mod tidy && go test ./... && go run .` to get started.\n", o.Dir)
+	} else {
+		fmt.Fprintf(out, "\nRun `aide apply -f %s` to get started.\n", filepath.Join(o.Dir, "pipeline.yaml"))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zc2638/aide"
+)
+
+func TestInitOption_Run(t *testing.T) {
+	tests := []struct {
+		name  string
+		opt   InitOption
+		files []string
+	}{
+		{
+			name:  "case 1: pipeline",
+			opt:   InitOption{Name: "demo", Yes: true},
+			files: []string{"pipeline.schema.json", "pipeline.yaml", "resource/config.yaml"},
+		},
+		{
+			name:  "case 2: go installer",
+			opt:   InitOption{Name: "demo", Go: true, Yes: true},
+			files: []string{"go.mod", "main.go", "main_test.go", "resource/config.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opt := tt.opt
+			opt.Dir = dir
+			var out strings.Builder
+			if err := opt.Run(&out); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var files []string
+			err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, name)
+				files = append(files, filepath.ToSlash(rel))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("files = %v, want %v", files, tt.files)
+			}
+
+			for _, name := range files {
+				switch filepath.Ext(name) {
+				case ".go":
+					if _, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, 0); err != nil {
+						t.Errorf("parse %s error = %v", name, err)
+					}
+				case ".json":
+					b, err := os.ReadFile(filepath.Join(dir, name))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !json.Valid(b) {
+						t.Errorf("%s is not valid json", name)
+					}
+				}
+			}
+			if opt.Go {
+				b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(b), "module example.com/demo\n") {
+					t.Errorf("go.mod = %s", b)
+				}
+				return
+			}
+			b, err := os.ReadFile(filepath.Join(dir, "pipeline.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errs := aide.ValidatePipeline(b, nil, dir); len(errs) > 0 {
+				t.Errorf("ValidatePipeline() = %v", errs)
+			}
+
+			// The existing files are not overwritten without force.
+			if err := opt.Run(&out); err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Errorf("Run() error = %v, want the existing file error", err)
+			}
+			opt.Force = true
+			if err := opt.Run(&out); err != nil {
+				t.Errorf("Run() with force error = %v", err)
+			}
+		})
+	}
+
+	opt := InitOption{Dir: t.TempDir(), Name: "Invalid Name", Yes: true}
+	if err := opt.Run(&strings.Builder{}); err == nil {
+		t.Error("Run() should reject the invalid name")
+	}
+}
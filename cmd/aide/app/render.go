@@ -0,0 +1,93 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+type RenderOption struct {
+	Source
+	Values Values
+
+	Steps []string
+	// Out is the directory to write to, "-" for stdout.
+	Out string
+}
+
+func NewRenderCmd() *cobra.Command {
+	opt := &RenderOption{}
+	cmd := &cobra.Command{
+		Use:                "render",
+		Short:              "Run only the render steps of the pipeline",
+		SilenceUsage:       true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pipeline, err := opt.LoadWithFlags(cmd, args, &opt.Values)
+			if err != nil || pipeline == nil {
+				return err
+			}
+			if err := pipeline.Validate(); err != nil {
+				return err
+			}
+
+			if opt.Out != "-" {
+				return pipeline.Render(cmd.Context(), opt.Out, opt.Steps...)
+			}
+			dir, err := os.MkdirTemp("", "aide-render-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+			if err := pipeline.Render(cmd.Context(), dir, opt.Steps...); err != nil {
+				return err
+			}
+			return printFiles(cmd.OutOrStdout(), dir)
+		},
+	}
+	opt.Source.AddFlags(cmd, "that contains the configuration to render, \"-\" for stdin or a http(s) URL")
+	opt.Values.AddFlags(cmd)
+	cmd.Flags().StringSliceVar(&opt.Steps, "step", opt.Steps, "the names of the render steps to run, defaults to all")
+	cmd.Flags().StringVar(&opt.Out, "out", opt.Out, "the scratch directory to write to instead of the dest, \"-\" for stdout")
+	return cmd
+}
+
+// printFiles prints the files in dir with their paths relative to dir as headers.
+func printFiles(out io.Writer, dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "==> %s <==\n%s", rel, b)
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			fmt.Fprintln(out)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRenderCmd(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pipeline.yaml": `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: render
+spec:
+  prompts:
+    - name: app
+      type: Input
+      default: demo
+  steps:
+    - name: config
+      render:
+        src: config.in
+        dest: /etc/{{ .env.app }}.conf
+    - name: backup
+      render:
+        src: backup.in
+        dest: /etc/{{ .env.app }}.bak
+`,
+		"config.in":   "app={{ .env.app }}",
+		"backup.in":   "from={{ .env.config_dest }}",
+		"values.yaml": "app: web\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "pipeline.yaml")
+	values := filepath.Join(dir, "values.yaml")
+
+	tests := []struct {
+		name    string
+		args    []string
+		out     string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "case 1: scratch directory",
+			args: []string{"--values", values},
+			out:  "scratch",
+			files: map[string]string{
+				"etc/web.conf": "app=web",
+				"etc/web.bak":  "from=/etc/web.conf",
+			},
+		},
+		{
+			name:  "case 2: selected step",
+			args:  []string{"--step", "backup"},
+			out:   "scratch",
+			files: map[string]string{"etc/demo.bak": "from=/etc/demo.conf"},
+		},
+		{
+			name: "case 3: stdout",
+			args: []string{"--out", "-", "--step", "config"},
+		},
+		{
+			name:    "case 4: undefined step",
+			args:    []string{"--step", "missing"},
+			out:     "scratch",
+			wantErr: "render step missing is not defined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-f", file, "--skip-prompt"}, tt.args...)
+			out := filepath.Join(t.TempDir(), tt.out)
+			if len(tt.out) > 0 {
+				args = append(args, "--out", out)
+			}
+			var stdout strings.Builder
+			cmd := NewRenderCmd()
+			cmd.SetArgs(args)
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&strings.Builder{})
+			err := cmd.ExecuteContext(context.Background())
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			for name, content := range tt.files {
+				b, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != content {
+					t.Errorf("%s = %q, want %q", name, b, content)
+				}
+			}
+			if len(tt.out) > 0 {
+				if stdout.Len() > 0 {
+					t.Errorf("stdout = %q, want empty", stdout.String())
+				}
+				return
+			}
+			if want := "==> " + filepath.Join("etc", "demo.conf") + " <==\napp=demo\n"; stdout.String() != want {
+				t.Errorf("stdout = %q, want %q", stdout.String(), want)
+			}
+		})
+	}
+	// The dest of the pipeline is never written by the render command.
+	if _, err := os.Stat("/etc/demo.conf"); !os.IsNotExist(err) {
+		t.Errorf("dest should not be written, stat error = %v", err)
+	}
+}
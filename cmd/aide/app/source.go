@@ -0,0 +1,316 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zc2638/aide"
+)
+
+// Source defines where the pipeline configuration comes from.
+type Source struct {
+	// Path is the location of the pipeline, it can be a local path,
+	// `-` for stdin, or a http(s) URL.
+	Path string
+	// SHA256 is the expected sha256 checksum of the pipeline content.
+	// Only the pipeline file is pinned, so a pinned remote pipeline cannot
+	// reference the relative script files and nested pipelines next to it.
+	SHA256 string
+}
+
+// AddFlags binds the source flags to cmd.
+func (s *Source) AddFlags(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringVarP(&s.Path, "file", "f", s.Path, usage)
+	cmd.Flags().StringVar(&s.SHA256, "sha256", s.SHA256, "the expected sha256 checksum of the configuration")
+}
+
+// pipelineSource is the content of the pipeline read from a Source.
+type pipelineSource struct {
+	name string
+	data []byte
+	// fsys and dir are used to resolve the relative render sources.
+	fsys fs.FS
+	dir  string
+	// key identifies the source in the state dir, it is empty for stdin.
+	key string
+}
+
+func (s *Source) read(ctx context.Context) (*pipelineSource, error) {
+	if len(s.Path) == 0 {
+		return nil, errors.New("please specify the configuration file to execute")
+	}
+
+	var (
+		ps  *pipelineSource
+		err error
+	)
+	switch {
+	case s.Path == "-":
+		var data []byte
+		data, err = io.ReadAll(os.Stdin)
+		ps = &pipelineSource{name: "<stdin>", data: data}
+		promptFromTTY()
+	case strings.HasPrefix(s.Path, "http://") || strings.HasPrefix(s.Path, "https://"):
+		ps, err = readURL(ctx, s.Path)
+	default:
+		var data []byte
+		data, err = os.ReadFile(s.Path)
+		ps = &pipelineSource{name: s.Path, data: data, dir: filepath.Dir(s.Path)}
+		ps.key, _ = filepath.Abs(s.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.SHA256) > 0 {
+		sum := sha256.Sum256(ps.data)
+		actual := hex.EncodeToString(sum[:])
+		if !strings.EqualFold(actual, s.SHA256) {
+			return nil, fmt.Errorf("sha256 mismatch for %s: expect %s, got %s", ps.name, s.SHA256, actual)
+		}
+		if err := ps.checkPinned(); err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// checkPinned rejects the relative script files and nested pipelines of the remote pipeline,
+// they are fetched without the checksum and would run the unverified code.
+func (ps *pipelineSource) checkPinned() error {
+	if ps.fsys == nil {
+		return nil
+	}
+	pipeline, err := aide.ParsePipeline(ps.data, nil, "")
+	if err != nil {
+		// The parse error is reported when the pipeline is loaded.
+		return nil
+	}
+	for _, step := range pipeline.Spec.Steps {
+		file := step.ScriptFile
+		if step.Uses == aide.PipelineStepType {
+			file, _ = step.With["file"].(string)
+		}
+		if len(file) > 0 && !filepath.IsAbs(file) {
+			return fmt.Errorf("step %s references %s which is not covered by the sha256 checksum of %s", step.Name, file, ps.name)
+		}
+	}
+	return nil
+}
+
+// promptFromTTY reads the prompts from the terminal since stdin is used up by the configuration,
+// stdin is kept if there is no terminal, e.g. in CI.
+func promptFromTTY() {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return
+	}
+	os.Stdin = tty
+}
+
+// Load reads and parses the pipeline from the source.
+func (s *Source) Load(ctx context.Context) (*aide.Pipeline, error) {
+	ps, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := aide.ParsePipeline(ps.data, ps.fsys, ps.dir)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.SetStateKey(ps.key)
+	return pipeline, nil
+}
+
+// LoadWithFlags parses the flags of cmd, loads the pipeline from the source,
+// and then parses the flags again with the prompts of the pipeline bound as flags.
+// The answers in values take effect before the flags, and values can be nil.
+// The cmd must disable flag parsing, a nil pipeline is returned when help is requested.
+func (s *Source) LoadWithFlags(cmd *cobra.Command, args []string, values *Values) (*aide.Pipeline, error) {
+	flags := cmd.Flags()
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if help, _ := flags.GetBool("help"); help {
+		return nil, cmd.Help()
+	}
+
+	pipeline, err := s.Load(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		if err := values.Apply(pipeline); err != nil {
+			return nil, err
+		}
+	}
+
+	// The run state is persisted by default in the command line.
+	pipeline.SetStateDir(aide.DefaultStateDir())
+	set := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	pipeline.BindFlags(set)
+	flags.AddGoFlagSet(set)
+	flags.ParseErrorsWhitelist.UnknownFlags = false
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// Values defines the file which contains the answers of the prompts.
+type Values struct {
+	Path string
+}
+
+// AddFlags binds the values flags to cmd.
+func (v *Values) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&v.Path, "values", v.Path, "the yaml file which contains the answers of the prompts, lists are joined by comma")
+}
+
+// Apply sets the answers in the values file as the defaults of the prompts.
+func (v *Values) Apply(pipeline *aide.Pipeline) error {
+	if len(v.Path) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(v.Path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse values file %s failed: %v", v.Path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch val := value.(type) {
+		case nil:
+			values[name] = ""
+		case []interface{}:
+			parts := make([]string, 0, len(val))
+			for _, item := range val {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(parts, ",")
+		default:
+			values[name] = fmt.Sprint(val)
+		}
+	}
+	return pipeline.SetValues(values)
+}
+
+func readURL(ctx context.Context, rawURL string) (*pipelineSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := httpGet(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	base := *u
+	base.Path = path.Dir(u.Path)
+	base.RawQuery = ""
+	base.Fragment = ""
+	return &pipelineSource{
+		name: rawURL,
+		data: data,
+		fsys: &httpFS{ctx: ctx, base: &base},
+		dir:  ".",
+		key:  rawURL,
+	}, nil
+}
+
+func httpGet(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s failed: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// httpFS is a read-only fs.FS which fetches files relative to the base URL.
+// Directories cannot be listed over http, so only files are supported.
+type httpFS struct {
+	ctx  context.Context
+	base *url.URL
+}
+
+func (h *httpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	u := *h.base
+	u.Path = path.Join(u.Path, name)
+	data, err := httpGet(h.ctx, u.String())
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &httpFile{
+		Reader: bytes.NewReader(data),
+		info:   httpFileInfo{name: path.Base(name), size: int64(len(data))},
+	}, nil
+}
+
+type httpFile struct {
+	*bytes.Reader
+	info httpFileInfo
+}
+
+func (f *httpFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *httpFile) Close() error { return nil }
+
+type httpFileInfo struct {
+	name string
+	size int64
+}
+
+func (i httpFileInfo) Name() string       { return i.name }
+func (i httpFileInfo) Size() int64        { return i.size }
+func (i httpFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i httpFileInfo) ModTime() time.Time { return time.Time{} }
+func (i httpFileInfo) IsDir() bool        { return false }
+func (i httpFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,147 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sourceTestPipeline = `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: source
+spec:
+  steps:
+    - name: config
+      render:
+        src: config.in
+        dest: /tmp/config
+`
+
+func sum(data string) string {
+	s := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(s[:])
+}
+
+func TestSource_Load(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pipeline.yaml")
+	if err := os.WriteFile(file, []byte(sourceTestPipeline), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdin := filepath.Join(dir, "stdin.yaml")
+	if err := os.WriteFile(stdin, []byte(sourceTestPipeline), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		source  Source
+		wantSrc string
+		wantErr string
+	}{
+		{name: "case 1: local file", source: Source{Path: file}, wantSrc: filepath.Join(dir, "config.in")},
+		{name: "case 2: stdin", source: Source{Path: "-"}, wantSrc: "config.in"},
+		{name: "case 3: sha256 matched", source: Source{Path: file, SHA256: strings.ToUpper(sum(sourceTestPipeline))}, wantSrc: filepath.Join(dir, "config.in")},
+		{name: "case 4: sha256 mismatch", source: Source{Path: file, SHA256: sum("other")}, wantErr: "sha256 mismatch"},
+		{name: "case 5: stdin sha256 mismatch", source: Source{Path: "-", SHA256: sum("other")}, wantErr: "sha256 mismatch for <stdin>"},
+		{name: "case 6: empty path", source: Source{}, wantErr: "please specify"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			origin := os.Stdin
+			os.Stdin = f
+			defer func() { os.Stdin = origin }()
+
+			pipeline, err := tt.source.Load(context.Background())
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := pipeline.Spec.Steps[0].Render.Src; got != tt.wantSrc {
+				t.Errorf("render.src = %s, want %s", got, tt.wantSrc)
+			}
+		})
+	}
+}
+
+func TestSource_Load_remote(t *testing.T) {
+	scripted := strings.Replace(sourceTestPipeline, "      render:\n        src: config.in\n        dest: /tmp/config\n",
+		"      scriptFile: scripts/install.sh\n", 1)
+	nested := strings.Replace(sourceTestPipeline, "      render:\n        src: config.in\n        dest: /tmp/config\n",
+		"      uses: pipeline\n      with:\n        file: nested.yaml\n", 1)
+	files := map[string]string{
+		"/pipelines/pipeline.yaml":      sourceTestPipeline,
+		"/pipelines/scripted.yaml":      scripted,
+		"/pipelines/nested.yaml":        nested,
+		"/pipelines/scripts/install.sh": "true",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		source  Source
+		wantErr string
+	}{
+		{name: "case 1: render source", source: Source{Path: server.URL + "/pipelines/pipeline.yaml"}},
+		{name: "case 2: pinned render source", source: Source{Path: server.URL + "/pipelines/pipeline.yaml", SHA256: sum(sourceTestPipeline)}},
+		{name: "case 3: script file", source: Source{Path: server.URL + "/pipelines/scripted.yaml"}},
+		{name: "case 4: pinned script file", source: Source{Path: server.URL + "/pipelines/scripted.yaml", SHA256: sum(scripted)}, wantErr: "not covered by the sha256 checksum"},
+		{name: "case 5: pinned nested pipeline", source: Source{Path: server.URL + "/pipelines/nested.yaml", SHA256: sum(nested)}, wantErr: "references nested.yaml"},
+		{name: "case 6: sha256 mismatch", source: Source{Path: server.URL + "/pipelines/pipeline.yaml", SHA256: sum(scripted)}, wantErr: "sha256 mismatch"},
+		{name: "case 7: not found", source: Source{Path: server.URL + "/pipelines/missing.yaml"}, wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.source.Load(context.Background())
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+		})
+	}
+}
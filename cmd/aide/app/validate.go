@@ -0,0 +1,83 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zc2638/aide"
+)
+
+type ValidateOption struct {
+	Source
+}
+
+func NewValidateCmd() *cobra.Command {
+	opt := &ValidateOption{}
+	cmd := &cobra.Command{
+		Use:          "validate",
+		Short:        "Check the pipeline configuration and report all the problems",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := opt.read(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			errs := aide.ValidatePipeline(ps.data, ps.fsys, ps.dir)
+			return reportFieldErrors(cmd, ps.name, errs, errs.Errors())
+		},
+	}
+	opt.Source.AddFlags(cmd, "that contains the configuration to validate, \"-\" for stdin or a http(s) URL")
+	return cmd
+}
+
+type LintOption struct {
+	Source
+}
+
+func NewLintCmd() *cobra.Command {
+	opt := &LintOption{}
+	cmd := &cobra.Command{
+		Use:          "lint",
+		Short:        "Check the variables referenced by the render templates and the commands",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := opt.read(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			errs := aide.LintPipeline(ps.data, ps.fsys, ps.dir)
+			return reportFieldErrors(cmd, ps.name, errs, errs)
+		},
+	}
+	opt.Source.AddFlags(cmd, "that contains the configuration to lint, \"-\" for stdin or a http(s) URL")
+	return cmd
+}
+
+// reportFieldErrors prints all the problems as `file:line:col: message`,
+// and returns an error if any of the failures exists.
+func reportFieldErrors(cmd *cobra.Command, name string, problems, failures aide.FieldErrors) error {
+	for _, e := range problems {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", name, e)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d problem(s) found in %s", len(failures), name)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultShell runs the commands and scripts if the step does not define the shell.
+const DefaultShell = "/bin/sh"
+
+// runsScript reports whether the step runs a command, a script or a script file.
+func (s *SpecStep) runsScript() bool {
+	return s.Command != nil || len(s.Script) > 0 || len(s.ScriptFile) > 0
+}
+
+// posixShell reports whether the shell of the step is sh or bash,
+// whose variables can be linted and which run in the strict mode.
+func (s *SpecStep) posixShell() bool {
+	switch s.shellName() {
+	case "sh", "bash", "dash":
+		return true
+	}
+	return false
+}
+
+// shellName returns the base name of the shell.
+func (s *SpecStep) shellName() string {
+	fields := strings.Fields(s.Shell)
+	if len(fields) == 0 {
+		return filepath.Base(DefaultShell)
+	}
+	return filepath.Base(fields[0])
+}
+
+// runsFile reports whether the script is run as a file instead of an argument of the shell.
+// They are the script files, whose size may exceed the limit of the arguments, and the inline
+// scripts of the single word shells other than sh and bash, whose -c may mean something else,
+// e.g. --check of node.
+func (s *SpecStep) runsFile() bool {
+	if len(s.ScriptFile) > 0 {
+		return true
+	}
+	return len(strings.Fields(s.Shell)) == 1 && !s.posixShell()
+}
+
+// shellArgs returns the command line which runs script, which is the path of the file
+// if the step runs the script as a file. A single word sh or bash runs the inline script with -c,
+// otherwise the script is appended to the args of the shell. The sh and bash run with set -eu
+// (bash also with -o pipefail) unless the strict mode is disabled.
+func (s *SpecStep) shellArgs(script string) []string {
+	fields := strings.Fields(s.Shell)
+	if len(fields) == 0 {
+		fields = []string{DefaultShell}
+	}
+	if len(fields) == 1 && !s.runsFile() {
+		fields = append(fields, "-c")
+	}
+	args := []string{fields[0]}
+	if (s.Strict == nil || *s.Strict) && s.posixShell() {
+		args = append(args, "-eu")
+		if s.shellName() == "bash" {
+			args = append(args, "-o", "pipefail")
+		}
+	}
+	args = append(args, fields[1:]...)
+	return append(args, script)
+}
+
+// inlineScript returns the command or the script and the name of its field,
+// the field is empty if the step runs a script file.
+func (s *SpecStep) inlineScript() (string, string) {
+	switch {
+	case s.Command != nil:
+		return *s.Command, "command"
+	case len(s.Script) > 0:
+		return s.Script, "script"
+	}
+	return "", ""
+}
+
+// script returns the script of the step and its description used in the errors.
+func (s *SpecStep) script() (string, string, error) {
+	switch script, field := s.inlineScript(); field {
+	case "command":
+		return script, fmt.Sprintf("command (%s)", script), nil
+	case "script":
+		return script, field, nil
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if s.fsys != nil {
+		b, err = fs.ReadFile(s.fsys, s.ScriptFile)
+	} else {
+		b, err = os.ReadFile(s.ScriptFile)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("read script file failed: %v", err)
+	}
+	return string(b), fmt.Sprintf("script file (%s)", s.ScriptFile), nil
+}
+
+// runScript runs the command or the script of the step in its working directory,
+// the output is written to stdout and stderr.
+func (p *Pipeline) runScript(ctx context.Context, envSet map[string]string, step *SpecStep, stdout, stderr io.Writer) error {
+	script, desc, err := step.script()
+	if err != nil {
+		return err
+	}
+	env, err := p.stepEnv(envSet, step)
+	if err != nil {
+		return err
+	}
+
+	if step.runsFile() {
+		file, err := writeScriptFile(script, filepath.Ext(step.ScriptFile))
+		if err != nil {
+			return fmt.Errorf("write %s failed: %v", desc, err)
+		}
+		defer os.Remove(file)
+		script = file
+	}
+
+	args := step.shellArgs(script)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if len(step.Workdir) > 0 {
+		dir, err := renderText(envSet, "workdir", step.Workdir)
+		if err != nil {
+			return fmt.Errorf("evaluate workdir failed: %v", err)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create workdir failed: %v", err)
+		}
+		cmd.Dir = dir
+	}
+	cmd.Env = envToSlice(env)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run %s failed: %w", desc, err)
+	}
+	return nil
+}
+
+// writeScriptFile writes script to a temporary file which only the owner can access,
+// the extension is kept for the interpreters which require it. The caller removes the file.
+func writeScriptFile(script, ext string) (string, error) {
+	f, err := os.CreateTemp("", "aide-script-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	_, err = f.WriteString(script)
+	if err == nil {
+		err = f.Chmod(0o700)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSpecStep_shellArgs(t *testing.T) {
+	off := false
+	tests := []struct {
+		step SpecStep
+		want []string
+	}{
+		{step: SpecStep{}, want: []string{"/bin/sh", "-eu", "-c", "true"}},
+		{step: SpecStep{Shell: "bash"}, want: []string{"bash", "-eu", "-o", "pipefail", "-c", "true"}},
+		{step: SpecStep{Shell: "bash", Strict: &off}, want: []string{"bash", "-c", "true"}},
+		{step: SpecStep{Shell: "python3 -c"}, want: []string{"python3", "-c", "true"}},
+		// The script is the path of the file for the script files and the other single word shells.
+		{step: SpecStep{Shell: "node"}, want: []string{"node", "true"}},
+		{step: SpecStep{ScriptFile: "install.sh"}, want: []string{"/bin/sh", "-eu", "true"}},
+		{step: SpecStep{Shell: "python3 -u", ScriptFile: "install.py"}, want: []string{"python3", "-u", "true"}},
+	}
+	for _, tt := range tests {
+		if got := tt.step.shellArgs("true"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellArgs() of %q = %v, want %v", tt.step.Shell, got, tt.want)
+		}
+	}
+}
+
+func TestPipeline_script(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not found")
+	}
+	dir := t.TempDir()
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: script
+spec:
+  prompts:
+    - name: app
+      type: Input
+      default: demo
+  steps:
+    - name: bash
+      shell: bash
+      workdir: "` + dir + `/{{ .env.app }}/work"
+      script: |
+        words=(hello "$app")
+        echo "${words[@]}" > out
+    - name: file
+      workdir: "` + dir + `"
+      scriptFile: scripts/install.sh
+`
+	fsys := fstest.MapFS{
+		"resource/pipeline.yaml": {Data: []byte(data)},
+		// The script file larger than the limit of the arguments.
+		"resource/scripts/install.sh": {Data: []byte("#" + strings.Repeat("x", 200<<10) + "\ncat demo/work/out > file.out\necho \"$0\" > script.path\n")},
+	}
+	p, err := LoadPipeline(fsys, "resource/pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p.skipPrompt = true
+	p.SetStateDir("")
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "file.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello demo\n" {
+		t.Errorf("output = %q", b)
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "script.path"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(b))); !os.IsNotExist(err) {
+		t.Errorf("the temporary script file %s should be removed, stat error = %v", b, err)
+	}
+
+	// The failed commands are not ignored in the strict mode.
+	p = NewPipeline("strict")
+	p.AddStep("", nil, "false; echo unreachable")
+	p.SetStateDir("")
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err == nil {
+		t.Error("Execute() should fail in the strict mode")
+	}
+	off := false
+	p.Spec.Steps[0].Strict = &off
+	if err := p.Execute(context.Background()); err != nil {
+		t.Errorf("Execute() error = %v without the strict mode", err)
+	}
+
+	p.Spec.Steps[0].Script = "true"
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject both command and script")
+	}
+}
@@ -17,8 +17,10 @@ package aide
 import (
 	"fmt"
 	"html/template"
+	"path"
 	"regexp"
 	"sort"
+	"strings"
 )
 
 var tpl = template.New("aide")
@@ -37,6 +39,35 @@ func ValidateName(name string) error {
 	return nil
 }
 
+// parseTemplate parses text as a new template based on tpl.
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.New(name).Parse(text)
+}
+
+func templateData(envSet map[string]string) map[string]interface{} {
+	return map[string]interface{}{"env": envSet, "host": hostFacts(), "item": envSet[ItemEnvName]}
+}
+
+// matchGlobs reports whether name matches any of the patterns.
+// A pattern without a slash is also matched against the base name of name.
+func matchGlobs(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func envToSlice(set map[string]string) []string {
 	env := make([]string, 0, len(set))
 	for k, v := range set {
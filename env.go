@@ -0,0 +1,181 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// InheritAll inherits all the environment variables of the host.
+	InheritAll = "all"
+	// InheritNone inherits none of the environment variables of the host.
+	InheritNone = "none"
+)
+
+// EnvNameRegexp defines the valid name of the environment variables.
+var EnvNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvInherit defines which environment variables of the host are inherited by the commands.
+// It is decoded from all, none, or a list of the names which can contain globs, e.g. [PATH, LC_*].
+type EnvInherit struct {
+	// Mode is InheritAll or InheritNone, it is ignored if Names is not empty.
+	Mode string
+	// Names defines the globs of the names of the inherited variables.
+	Names []string
+}
+
+// InheritNames returns the EnvInherit which only inherits the variables matching the globs.
+func InheritNames(names ...string) *EnvInherit {
+	return &EnvInherit{Names: names}
+}
+
+// allows reports whether the variable of the host is inherited, all are inherited if e is nil.
+func (e *EnvInherit) allows(name string) bool {
+	if e == nil {
+		return true
+	}
+	if len(e.Names) > 0 {
+		for _, pattern := range e.Names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return e.Mode != InheritNone
+}
+
+// validate checks the mode and the globs, field is the path of the field used in the errors.
+func (e *EnvInherit) validate(field string, addErr func(path, format string, args ...interface{})) {
+	if e == nil {
+		return
+	}
+	if len(e.Names) == 0 {
+		if e.Mode != InheritAll && e.Mode != InheritNone {
+			addErr(field, "unknown value %q, expect %s, %s or a list of names", e.Mode, InheritAll, InheritNone)
+		}
+		return
+	}
+	for i, pattern := range e.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			addErr(fmt.Sprintf("%s[%d]", field, i), "invalid glob %q: %v", pattern, err)
+		}
+	}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *EnvInherit) UnmarshalYAML(node *yaml.Node) error {
+	*e = EnvInherit{}
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&e.Names)
+	}
+	return node.Decode(&e.Mode)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (e EnvInherit) MarshalYAML() (interface{}, error) {
+	if len(e.Names) > 0 {
+		return e.Names, nil
+	}
+	return e.Mode, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *EnvInherit) UnmarshalJSON(b []byte) error {
+	*e = EnvInherit{}
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, &e.Names)
+	}
+	return json.Unmarshal(b, &e.Mode)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e EnvInherit) MarshalJSON() ([]byte, error) {
+	if len(e.Names) > 0 {
+		return json.Marshal(e.Names)
+	}
+	return json.Marshal(e.Mode)
+}
+
+// JSONSchema implements jsonSchemaer.
+func (EnvInherit) JSONSchema() *JSONSchema {
+	return &JSONSchema{OneOf: []*JSONSchema{
+		{Type: "string", Enum: []string{InheritAll, InheritNone}},
+		{Type: "array", Items: &JSONSchema{Type: "string"}},
+	}}
+}
+
+// environ converts the environment variables in the form of key=value to a map,
+// the values containing = are kept as they are.
+func environ(env []string) map[string]string {
+	set := make(map[string]string, len(env))
+	for _, item := range env {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) == 2 {
+			set[parts[0]] = parts[1]
+		}
+	}
+	return set
+}
+
+// stepEnv returns the environment variables of the command of the step. They are the variables
+// of the host inherited by the policy of the step or the pipeline, overridden by the variables
+// of the pipeline exported by commandEnv, then by the env of the step evaluated as templates.
+func (p *Pipeline) stepEnv(envSet map[string]string, step *SpecStep) (map[string]string, error) {
+	inherit := p.Spec.Inherit
+	if step.Inherit != nil {
+		inherit = step.Inherit
+	}
+	env := make(map[string]string)
+	for k, v := range environ(os.Environ()) {
+		if inherit.allows(k) {
+			env[k] = v
+		}
+	}
+	exported := p.commandEnv(envSet, step)
+	for k, v := range exported {
+		env[k] = v
+	}
+
+	// The env is evaluated with the exported variables only, so the secrets are not
+	// passed to the command through the env unless the step declares them.
+	for _, k := range sortedKeys(step.Env) {
+		value, err := renderText(exported, k, step.Env[k])
+		if err != nil {
+			return nil, fmt.Errorf("evaluate env %s failed: %v", k, err)
+		}
+		env[k] = value
+	}
+	return env, nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
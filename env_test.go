@@ -0,0 +1,97 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnviron(t *testing.T) {
+	got := environ([]string{"A=1", "B=x=y", "C=", "D"})
+	want := map[string]string{"A": "1", "B": "x=y", "C": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("environ() = %v, want %v", got, want)
+	}
+}
+
+func TestPipeline_envInherit(t *testing.T) {
+	t.Setenv("AIDE_TEST_EQ", "a=b")
+	t.Setenv("AIDE_TEST_OTHER", "other")
+	dir := t.TempDir()
+	out := func(name string) string { return filepath.Join(dir, name) }
+
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: env
+spec:
+  inherit: [ PATH, HOME, AIDE_TEST_E* ]
+  prompts:
+    - name: app
+      type: Input
+      default: demo
+  steps:
+    - name: pipeline
+      command: printf '%s|%s|%s|%s' "$AIDE_TEST_EQ" "${AIDE_TEST_OTHER-unset}" "$app" "$(cat /dev/null; echo ok)" > ` + out("pipeline") + `
+    - name: none
+      inherit: none
+      env:
+        GREETING: "hello {{ .env.app }}"
+      command: printf '%s|%s|%s' "${AIDE_TEST_EQ-unset}" "${HOME-unset}" "$GREETING" > ` + out("none") + `
+    - name: all
+      inherit: all
+      command: printf '%s' "$AIDE_TEST_OTHER" > ` + out("all") + `
+`
+	p, err := ParsePipeline([]byte(data), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p.skipPrompt = true
+	p.SetStateDir("")
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"pipeline": "a=b|unset|demo|ok",
+		"none":     "unset|unset|hello demo",
+		"all":      "other",
+	} {
+		b, err := os.ReadFile(out(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("step %s output = %q, want %q", name, b, want)
+		}
+	}
+
+	p.Spec.Steps[1].Inherit = &EnvInherit{Mode: "some"}
+	p.Spec.Steps[1].Env["1BAD"] = "x"
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), `unknown value "some"`) ||
+		!strings.Contains(err.Error(), `invalid environment variable name "1BAD"`) {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
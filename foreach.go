@@ -0,0 +1,214 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+// ItemEnvName is the environment variable of the item of the step with forEach,
+// the item is also available as {{ .item }} in the templates.
+const ItemEnvName = "AIDE_ITEM"
+
+// StepForEach defines the items of the step. It is decoded from the name of a variable
+// whose value is split by comma like the answers of MultiSelect, or from a list of the items
+// which can contain templates.
+type StepForEach struct {
+	// Var is the name of the variable, it is ignored if Items is not empty.
+	Var string
+	// Items defines the items, the items evaluated as empty are dropped.
+	Items []string
+}
+
+// items returns the items evaluated against envSet.
+func (f *StepForEach) items(envSet map[string]string) ([]string, error) {
+	var items []string
+	if len(f.Items) == 0 {
+		for _, item := range strings.Split(envSet[f.Var], ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	for i, item := range f.Items {
+		value, err := renderText(envSet, fmt.Sprintf("forEach[%d]", i), item)
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > 0 {
+			items = append(items, value)
+		}
+	}
+	return items, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (f *StepForEach) UnmarshalYAML(node *yaml.Node) error {
+	*f = StepForEach{}
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&f.Items)
+	}
+	return node.Decode(&f.Var)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (f StepForEach) MarshalYAML() (interface{}, error) {
+	if len(f.Items) > 0 {
+		return f.Items, nil
+	}
+	return f.Var, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *StepForEach) UnmarshalJSON(b []byte) error {
+	*f = StepForEach{}
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, &f.Items)
+	}
+	return json.Unmarshal(b, &f.Var)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f StepForEach) MarshalJSON() ([]byte, error) {
+	if len(f.Items) > 0 {
+		return json.Marshal(f.Items)
+	}
+	return json.Marshal(f.Var)
+}
+
+// JSONSchema implements jsonSchemaer.
+func (StepForEach) JSONSchema() *JSONSchema {
+	return &JSONSchema{OneOf: []*JSONSchema{
+		{Type: "string"},
+		{Type: "array", Items: &JSONSchema{Type: "string"}},
+	}}
+}
+
+// stepRun defines a run of the step, the step with forEach runs once per item.
+type stepRun struct {
+	index int
+	// name is the unique name of the run in the logs and the report.
+	name string
+	// num is the number of the run in the report, starting from 1.
+	num  int
+	item string
+}
+
+// stepRuns expands the steps into the runs, the steps without forEach run once.
+func (p *Pipeline) stepRuns(envSet map[string]string) ([][]stepRun, error) {
+	runs := make([][]stepRun, len(p.Spec.Steps))
+	num := 0
+	for k, step := range p.Spec.Steps {
+		if step.ForEach == nil {
+			num++
+			runs[k] = []stepRun{{index: k, name: p.stepName(k), num: num}}
+			continue
+		}
+		items, err := step.ForEach.items(envSet)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate forEach of step %s failed: %v", p.stepName(k), err)
+		}
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if seen[item] {
+				return nil, fmt.Errorf("duplicate item %q in forEach of step %s", item, p.stepName(k))
+			}
+			seen[item] = true
+			num++
+			runs[k] = append(runs[k], stepRun{
+				index: k,
+				name:  fmt.Sprintf("%s[%s]", p.stepName(k), item),
+				num:   num,
+				item:  item,
+			})
+		}
+	}
+	return runs, nil
+}
+
+// executeRuns executes the runs of the step k, the runs of the step with forEach
+// use copies of envSet with the item, and the registered outputs are copied back in order.
+func (p *Pipeline) executeRuns(ctx context.Context, envSet map[string]string, k int, runs []stepRun) error {
+	step := &p.Spec.Steps[k]
+	if step.ForEach == nil {
+		return p.executeRun(ctx, envSet, runs[0])
+	}
+
+	envSets := make([]map[string]string, len(runs))
+	for i, run := range runs {
+		envSets[i] = itemEnv(envSet, run.item)
+	}
+	limit := 1
+	if step.Parallel > 1 {
+		limit = step.Parallel
+	}
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(limit)
+	for i := range runs {
+		i := i
+		eg.Go(func() error {
+			return p.executeRun(egCtx, envSets[i], runs[i])
+		})
+	}
+	err := eg.Wait()
+	for _, runEnv := range envSets {
+		copyOutputs(envSet, runEnv, step)
+	}
+	return err
+}
+
+// itemEnv returns a copy of envSet with the item of the run.
+func itemEnv(envSet map[string]string, item string) map[string]string {
+	env := make(map[string]string, len(envSet)+1)
+	for name, value := range envSet {
+		env[name] = value
+	}
+	env[ItemEnvName] = item
+	return env
+}
+
+// copyOutputs copies the outputs of the step registered in the variable set of the run into envSet.
+func copyOutputs(envSet, runEnv map[string]string, step *SpecStep) {
+	for _, name := range step.Outputs() {
+		if value, ok := runEnv[name]; ok {
+			envSet[name] = value
+		}
+	}
+}
+
+// executeRun executes the run and reports its result.
+func (p *Pipeline) executeRun(ctx context.Context, envSet map[string]string, run stepRun) error {
+	if err := ctx.Err(); err != nil {
+		p.reporter.skipStep(0, run.num)
+		return err
+	}
+	p.reporter.startStep(0, run.num)
+	if p.logger != nil {
+		p.stepLogger(ctx, run).Logf(Unknown, "=> %s", run.name)
+	}
+	err := p.executeStep(ctx, envSet, run)
+	p.reporter.finishStep(0, run.num, err)
+	if err != nil && p.logger != nil {
+		p.stepLogger(ctx, run).Log(ErrorLevel, p.redact(err.Error()))
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_forEach(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.in"), []byte("name={{ .item }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: loop
+spec:
+  prompts:
+    - name: components
+      type: MultiSelect
+      enum: [ api, web, worker ]
+      default: api,worker
+  steps:
+    - name: install
+      forEach: components
+      parallel: 2
+      command: printf '%s' "$AIDE_ITEM" > "` + dir + `/$AIDE_ITEM.installed"
+    - name: config
+      forEach: [ "{{ .env.components }}", db ]
+      render:
+        src: config.in
+        dest: "` + dir + `/{{ .item }}.conf"
+`
+	p, err := ParsePipeline([]byte(data), nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if errs := p.Lint(); len(errs) > 0 {
+		t.Errorf("Lint() = %v", errs)
+	}
+	p.skipPrompt = true
+	p.SetStateDir("")
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"api.installed":    "api",
+		"worker.installed": "worker",
+		"api,worker.conf":  "name=api,worker",
+		"db.conf":          "name=db",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+
+	var names []string
+	for _, step := range p.Report().Stages[0].Steps {
+		names = append(names, step.Name)
+	}
+	want := []string{"install[api]", "install[worker]", "config[api,worker]", "config[db]"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("report steps = %v, want %v", names, want)
+	}
+
+	// The render steps with forEach are rendered once per item like Execute.
+	out := t.TempDir()
+	if err := p.Render(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"api,worker.conf", "db.conf"} {
+		if _, err := os.Stat(filepath.Join(out, dir, name)); err != nil {
+			t.Errorf("Render() %s error = %v", name, err)
+		}
+	}
+
+	// The remaining items are not run after an item fails.
+	p.Spec.Steps[0].Parallel = 0
+	p.Spec.Steps[0].Command = strPtr(`test "$AIDE_ITEM" != api`)
+	if err := p.Execute(context.Background()); err == nil {
+		t.Fatal("Execute() should fail")
+	}
+	steps := p.Report().Stages[0].Steps
+	if steps[0].Status != StatusFailed || steps[1].Status != StatusSkipped {
+		t.Errorf("report steps = %+v", steps)
+	}
+
+	p.Spec.Steps[1].Parallel = -1
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject the negative parallel")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,215 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFallbackLocale is used when the pipeline does not define spec.fallbackLocale.
+const DefaultFallbackLocale = "en"
+
+// LocaleText is a text which can be localized. It is decoded from a plain string,
+// or from a map of the locale to the text, e.g. {en: "Name?", zh: "名称？"}.
+type LocaleText struct {
+	// Text is used when none of the Locales matches.
+	Text string
+	// Locales maps the locale, e.g. en or zh_CN, to the localized text.
+	Locales map[string]string
+}
+
+// NewLocaleText returns the LocaleText of the plain text.
+func NewLocaleText(text string) LocaleText {
+	return LocaleText{Text: text}
+}
+
+// Localize returns the text of locale. If it is not defined, the text of the same language,
+// then the text of fallback, then Text are used in order.
+func (t LocaleText) Localize(locale, fallback string) string {
+	if len(t.Locales) == 0 {
+		return t.Text
+	}
+	keys := sortedKeys(t.Locales)
+
+	for _, want := range []string{locale, fallback} {
+		want = normalizeLocale(want)
+		if len(want) == 0 {
+			continue
+		}
+		lang := strings.SplitN(want, "_", 2)[0]
+		// Prefer the exact locale, then the language, then another region of the language.
+		for _, match := range []func(key string) bool{
+			func(key string) bool { return key == want },
+			func(key string) bool { return key == lang },
+			func(key string) bool { return strings.SplitN(key, "_", 2)[0] == lang },
+		} {
+			for _, k := range keys {
+				if match(normalizeLocale(k)) {
+					return t.Locales[k]
+				}
+			}
+		}
+	}
+	if len(t.Text) > 0 {
+		return t.Text
+	}
+	return t.Locales[keys[0]]
+}
+
+// texts calls fn with each text, the key of Text is empty.
+func (t LocaleText) texts(fn func(key, text string)) {
+	if len(t.Text) > 0 {
+		fn("", t.Text)
+	}
+	keys := sortedKeys(t.Locales)
+	for _, k := range keys {
+		fn(k, t.Locales[k])
+	}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (t *LocaleText) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		*t = LocaleText{}
+		return node.Decode(&t.Locales)
+	}
+	*t = LocaleText{}
+	return node.Decode(&t.Text)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (t LocaleText) MarshalYAML() (interface{}, error) {
+	if len(t.Locales) == 0 {
+		return t.Text, nil
+	}
+	return t.Locales, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *LocaleText) UnmarshalJSON(b []byte) error {
+	*t = LocaleText{}
+	if len(b) > 0 && b[0] == '{' {
+		return json.Unmarshal(b, &t.Locales)
+	}
+	return json.Unmarshal(b, &t.Text)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t LocaleText) MarshalJSON() ([]byte, error) {
+	if len(t.Locales) == 0 {
+		return json.Marshal(t.Text)
+	}
+	return json.Marshal(t.Locales)
+}
+
+// JSONSchema implements jsonSchemaer.
+func (LocaleText) JSONSchema() *JSONSchema {
+	return &JSONSchema{OneOf: []*JSONSchema{
+		{Type: "string"},
+		{Type: "object", AdditionalProperties: &JSONSchema{Type: "string"}},
+	}}
+}
+
+// SystemLocale returns the locale of the environment from LC_ALL, LC_MESSAGES or LANG,
+// it returns empty for the C and POSIX locales.
+func SystemLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); len(value) > 0 {
+			if locale := normalizeLocale(value); locale == "c" || locale == "posix" {
+				return ""
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+// normalizeLocale converts the locale to a comparable form,
+// e.g. zh-CN and zh_CN.UTF-8 are converted to zh_cn.
+func normalizeLocale(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(strings.ReplaceAll(locale, "-", "_"))
+}
+
+// locale returns the locale of the prompts and its fallback.
+func (p *Pipeline) locale() (string, string) {
+	locale := p.lang
+	if len(locale) == 0 {
+		locale = SystemLocale()
+	}
+	fallback := p.Spec.FallbackLocale
+	if len(fallback) == 0 {
+		fallback = DefaultFallbackLocale
+	}
+	return locale, fallback
+}
+
+// SetLang sets the locale of the prompts, it defaults to the locale of the environment.
+func (p *Pipeline) SetLang(lang string) {
+	p.lang = lang
+}
+
+// langFlag is the flag of the locale of the prompts,
+// the usages of the flags of the prompts are localized again when it is set.
+type langFlag struct {
+	p   *Pipeline
+	set *flag.FlagSet
+}
+
+func (f *langFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return f.p.lang
+}
+
+func (f *langFlag) Set(lang string) error {
+	f.p.SetLang(lang)
+	locale, fallback := f.p.locale()
+	for k := range f.p.Spec.Prompts {
+		prompt := &f.p.Spec.Prompts[k]
+		if fl := f.set.Lookup(prompt.Name); fl != nil {
+			fl.Usage = prompt.flagUsage(locale, fallback)
+		}
+	}
+	return nil
+}
+
+// langFromArgs returns the value of the lang flag in args, the args after -- are ignored.
+func langFromArgs(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if len(name) == len(arg) || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == "lang" && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if value := strings.TrimPrefix(name, "lang="); len(value) < len(name) {
+			return value, true
+		}
+	}
+	return "", false
+}
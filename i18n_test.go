@@ -0,0 +1,167 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2/core"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLocaleText_Localize(t *testing.T) {
+	text := LocaleText{Locales: map[string]string{"en": "Name?", "zh_CN": "名称？", "fr": "Nom ?"}}
+	tests := []struct {
+		locale   string
+		fallback string
+		want     string
+	}{
+		{locale: "zh_CN.UTF-8", fallback: "en", want: "名称？"},
+		{locale: "zh-cn", fallback: "en", want: "名称？"},
+		{locale: "zh", fallback: "en", want: "名称？"},
+		{locale: "en_US", fallback: "zh", want: "Name?"},
+		{locale: "de", fallback: "fr", want: "Nom ?"},
+		{locale: "", fallback: "en", want: "Name?"},
+		{locale: "de", fallback: "ja", want: "Name?"},
+	}
+	for _, tt := range tests {
+		if got := text.Localize(tt.locale, tt.fallback); got != tt.want {
+			t.Errorf("Localize(%q, %q) = %q, want %q", tt.locale, tt.fallback, got, tt.want)
+		}
+	}
+	if got := NewLocaleText("Name?").Localize("zh", "en"); got != "Name?" {
+		t.Errorf("Localize() = %q, want the plain text", got)
+	}
+}
+
+func TestPipeline_localizedPrompts(t *testing.T) {
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: test
+spec:
+  fallbackLocale: en
+  prompts:
+    - name: app_name
+      type: Input
+      message: { en: "Name?", zh: "名称？" }
+      default: demo
+    - name: edition
+      type: Select
+      message:
+        en: "Edition of {{ .env.app_name }}?"
+        zh: "{{ .env.app_name }} 的版本？"
+      help: Edition
+      enum: [ community, enterprise ]
+      enumLabels:
+        community: { en: Community, zh: 社区版 }
+  steps:
+    - command: echo $edition
+`
+	p, err := ParsePipeline([]byte(data), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := p.Spec.Prompts[1].resolve(map[string]string{"app_name": "demo"}, "zh_CN.UTF-8", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Message.Text != "demo 的版本？" || v.Help.Text != "Edition" {
+		t.Errorf("resolve() = %+v", v)
+	}
+	if options := v.options(); !reflect.DeepEqual(options, []string{"社区版", "enterprise"}) {
+		t.Errorf("options() = %v", options)
+	}
+	// The answers are stored as the options instead of the labels.
+	if got := v.answerString(core.OptionAnswer{Value: "社区版", Index: 0}); got != "community" {
+		t.Errorf("answerString() = %q", got)
+	}
+
+	p.SetLang("fr")
+	p.skipPrompt = true
+	envSet := map[string]string{}
+	if err := p.executePrompts(context.Background(), envSet); err != nil {
+		t.Fatal(err)
+	}
+	if envSet["edition"] != "community" {
+		t.Errorf("answers = %v", envSet)
+	}
+
+	out, err := yaml.Marshal(p.Spec.Prompts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompt SpecPrompt
+	if err := yaml.Unmarshal(out, &prompt); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prompt.Message, p.Spec.Prompts[0].Message) {
+		t.Errorf("message = %+v after marshaled", prompt.Message)
+	}
+
+	p.Spec.Prompts[1].EnumLabels["free"] = NewLocaleText("Free")
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject the label of the unknown option")
+	}
+}
+
+func TestPipeline_BindFlags_lang(t *testing.T) {
+	p := NewPipeline("test")
+	p.AddInputPrompt("app_name", "Name?", "demo", "")
+	p.Spec.Prompts[0].Help = LocaleText{Locales: map[string]string{"en": "The name", "zh": "名称"}}
+	p.SetLang("en")
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	p.BindFlags(set)
+	if usage := set.Lookup("app_name").Usage; usage != "The name" {
+		t.Errorf("usage = %q, want the en help", usage)
+	}
+	if err := set.Parse([]string{"-lang", "zh"}); err != nil {
+		t.Fatal(err)
+	}
+	if usage := set.Lookup("app_name").Usage; usage != "名称" || p.lang != "zh" {
+		t.Errorf("usage = %q with lang %s, want the zh help", usage, p.lang)
+	}
+}
+
+func TestLangFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		ok   bool
+	}{
+		{args: []string{"-help", "--lang", "zh"}, want: "zh", ok: true},
+		{args: []string{"-lang=zh_CN", "-help"}, want: "zh_CN", ok: true},
+		{args: []string{"--lang="}, want: "", ok: true},
+		{args: []string{"--", "-lang", "zh"}},
+		{args: []string{"---lang", "zh"}},
+		{args: []string{"lang", "zh"}},
+		{args: []string{"-lang"}},
+	}
+	for _, tt := range tests {
+		got, ok := langFromArgs(tt.args)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("langFromArgs(%v) = %q, %v, want %q, %v", tt.args, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package aide
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/zc2638/aide/stage"
@@ -30,12 +31,19 @@ const (
 )
 
 type Instance struct {
-	instance *stage.Instance
-	logger   LogInterface
+	instance  *stage.Instance
+	logger    LogInterface
+	stages    []*Stage
+	reporter  *reporter
+	report    *Report
+	progress  *progress
+	observers []Observer
 
 	stageSymbol string
 	stepSymbol  string
 	verbose     bool
+	// showProgress indicates whether to render the progress on the terminal.
+	showProgress bool
 }
 
 func New(opts ...InstanceOption) *Instance {
@@ -48,7 +56,17 @@ func New(opts ...InstanceOption) *Instance {
 	for _, opt := range opts {
 		opt(ins)
 	}
-	ins.logger = newLog(ins.verbose)
+	if ins.showProgress && ins.verbose && ins.logger == nil {
+		// The progress falls back to the plain output if stdout is not a terminal.
+		if ins.progress = newProgress(os.Stdout); ins.progress != nil {
+			ins.logger = newProgressLog(ins.progress, 0)
+			// The running step is shown by the progress.
+			ins.stepSymbol = ""
+		}
+	}
+	if ins.logger == nil {
+		ins.logger = newLog(ins.verbose)
+	}
 	return ins
 }
 
@@ -65,10 +83,12 @@ func (i *Instance) AddStages(stages ...*Stage) *Instance {
 		if s.subFunc == nil {
 			s.subFunc = sub
 		}
-		s.instance.SetPreFunc(s.preFunc)
-		s.instance.SetSubFunc(s.subFunc)
-		s.instance.Skip(s.skip)
-		s.instance.SkipFunc(s.skipFunc)
+		s.owner = i
+		s.index = len(i.stages)
+		i.stages = append(i.stages, s)
+		s.instance.SetPreFunc(i.wrapPre(s, s.preFunc))
+		s.instance.SetSubFunc(i.wrapSub(s, s.subFunc))
+		s.instance.SkipFunc(i.wrapSkip(s))
 		i.instance.Add(s.instance)
 	}
 	return i
@@ -78,20 +98,75 @@ func (i *Instance) Run(ctx context.Context) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return i.instance.Run(ctx)
+	observers := i.observers
+	if i.progress != nil {
+		observers = append([]Observer{i.progress}, observers...)
+	}
+	i.reporter = newReporter("aide", observers...)
+	for _, s := range i.stages {
+		names := make([]string, 0, len(s.steps))
+		for _, step := range s.steps {
+			names = append(names, step.name)
+		}
+		i.reporter.addStage(s.name, names...)
+	}
+
+	err := i.instance.Run(ctx)
+	i.report = i.reporter.finish(err)
+	return err
+}
+
+// Report returns the report of the last run, it returns nil if it has not been run.
+func (i *Instance) Report() *Report {
+	return i.report
+}
+
+// wrapSkip records the stage which is skipped by Skip or SkipFunc.
+func (i *Instance) wrapSkip(s *Stage) func() bool {
+	return func() bool {
+		skipped := s.skip || (s.skipFunc != nil && s.skipFunc())
+		if skipped {
+			i.reporter.skipStage(s.index)
+		}
+		return skipped
+	}
+}
+
+// wrapPre records the start of the stage before calling f.
+func (i *Instance) wrapPre(s *Stage, f stage.InstanceFunc) stage.InstanceFunc {
+	return func(sc stage.Context) error {
+		i.reporter.startStage(s.index)
+		err := f(sc)
+		if err != nil {
+			i.reporter.finishStage(s.index, err)
+		}
+		return err
+	}
+}
+
+// wrapSub records the end of the stage after calling f.
+func (i *Instance) wrapSub(s *Stage, f stage.InstanceFunc) stage.InstanceFunc {
+	return func(sc stage.Context) error {
+		err := f(sc)
+		i.reporter.finishStage(s.index, err)
+		return err
+	}
 }
 
 func (i *Instance) buildPre(s *Stage) func(sc stage.Context) error {
 	return func(sc stage.Context) error {
 		sc.WithValue(StepTotalKey, s.total)
+		// Clear the step context of the previous stage.
+		sc.WithValue(StepCtxKey, nil)
 		stageName := stage.ContextName(sc)
 		if len(i.stageSymbol) == 0 {
 			return nil
 		}
+		logger := withContext(i.logger, sc)
 		if strings.Count(i.stageSymbol, "%s") > 0 {
-			i.logger.Logf(Unknown, i.stageSymbol, stageName)
+			logger.Logf(Unknown, i.stageSymbol, stageName)
 		} else {
-			i.logger.Log(Unknown, i.stageSymbol)
+			logger.Log(Unknown, i.stageSymbol)
 		}
 		return nil
 	}
@@ -117,6 +192,21 @@ func WithVerboseOption(verbose bool) InstanceOption {
 	}
 }
 
+// WithProgressOption renders the progress of the steps with spinners on the terminal,
+// it takes no effect if stdout is not a terminal or a logger is specified.
+func WithProgressOption(show bool) InstanceOption {
+	return func(i *Instance) {
+		i.showProgress = show
+	}
+}
+
+// WithObserverOption registers the observers of the lifecycle events.
+func WithObserverOption(observers ...Observer) InstanceOption {
+	return func(i *Instance) {
+		i.observers = append(i.observers, observers...)
+	}
+}
+
 func WithLogOption(log LogInterface) InstanceOption {
 	return func(i *Instance) {
 		i.logger = log
@@ -17,6 +17,7 @@
 package aide
 
 import (
+	"context"
 	"testing"
 )
 
@@ -57,3 +58,40 @@ func TestInstance_AddStages(t *testing.T) {
 		})
 	}
 }
+
+func TestInstance_Report(t *testing.T) {
+	first := NewStage("first").AddSteps(
+		StepFunc(func(sc *StepContext) {}).Step("ok"),
+		StepFunc(func(sc *StepContext) { sc.ErrorStr("failed") }).Step("failed"),
+		StepFunc(func(sc *StepContext) {}).Step("unreachable"),
+	)
+	second := NewStage("second").AddSteps(
+		StepFunc(func(sc *StepContext) {}).Step("unreachable"),
+	)
+
+	ins := New(WithVerboseOption(false))
+	ins.AddStages(first, second)
+	if err := ins.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail")
+	}
+
+	report := ins.Report()
+	if report.Status != StatusFailed || report.Error != "failed" || report.ExitCode != 1 {
+		t.Errorf("Report() = %+v", report)
+	}
+	want := [][]ReportStatus{
+		{StatusOK, StatusFailed, StatusSkipped},
+		{StatusSkipped},
+	}
+	wantStages := []ReportStatus{StatusFailed, StatusSkipped}
+	for i, s := range report.Stages {
+		if s.Status != wantStages[i] {
+			t.Errorf("stage %s status = %s, want %s", s.Name, s.Status, wantStages[i])
+		}
+		for j, step := range s.Steps {
+			if step.Status != want[i][j] {
+				t.Errorf("step %s/%s status = %s, want %s", s.Name, step.Name, step.Status, want[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/99nil/gopkg/sets"
+)
+
+// shellVars defines the variables provided by the shell itself.
+var shellVars = sets.NewString(
+	"HOME", "PATH", "PWD", "OLDPWD", "IFS", "PS1", "PS2", "PS4", "PPID", "UID", "EUID",
+	"USER", "SHELL", "RANDOM", "LINENO", "SECONDS", "OPTARG", "OPTIND", "HOSTNAME",
+)
+
+var (
+	// commandVarRegexp matches $name and ${name...} in commands.
+	commandVarRegexp = regexp.MustCompile(`(\\?)\$(?:\{([A-Za-z_][A-Za-z0-9_]*)([^}]*)}|([A-Za-z_][A-Za-z0-9_]*))`)
+	// commandAssignRegexp matches the variables defined by the commands themselves.
+	commandAssignRegexp = regexp.MustCompile(`(?:^|[\s;&|(])(?:(?:export|local|readonly)\s+)?([A-Za-z_][A-Za-z0-9_]*)=|\b(?:for|read|select)\s+(?:-r\s+)?([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// Outputs returns the names of the variables registered by the step during execution.
+func (s *SpecStep) Outputs() []string {
+	if len(s.Name) == 0 {
+		return nil
+	}
+	var outputs []string
+	if s.Render != nil {
+		outputs = append(outputs, s.Name+"_src", s.Name+"_dest")
+	}
+	if factory, ok := lookupStepType(s.Uses); ok {
+		if outputter, ok := factory().(StepOutputter); ok {
+			for _, name := range outputter.Outputs() {
+				outputs = append(outputs, s.Name+"_"+name)
+			}
+		}
+	}
+	for _, name := range s.outputs {
+		if !containsString(outputs, name) {
+			outputs = append(outputs, name)
+		}
+	}
+	return outputs
+}
+
+// Lint checks the variables referenced by the prompts, vars, render templates and commands.
+// The references to the variables which are not a prompt, label, var, registered output,
+// or host environment variable inherited by the command are reported as warnings,
+// so are the unused prompts and vars.
+// The templates which cannot be parsed are reported as errors.
+func (p *Pipeline) Lint() FieldErrors {
+	host := environ(os.Environ())
+	defined := sets.NewString()
+	for k := range p.Metadata.Labels {
+		defined.Add(k)
+	}
+
+	var errs FieldErrors
+	used := sets.NewString()
+	secrets := p.secretNames()
+	reference := func(path, location string, names []string, optional sets.String) {
+		for _, name := range names {
+			used.Add(name)
+			if defined.Has(name) || shellVars.Has(name) || optional.Has(name) {
+				continue
+			}
+			errs = append(errs, &FieldError{
+				Path:    path,
+				Message: fmt.Sprintf("%sundefined variable %q", location, name),
+				Warning: true,
+			})
+		}
+	}
+
+	// The variables of the host are only available to the commands which inherit them.
+	inherited := func(inherit *EnvInherit, optional sets.String) sets.String {
+		for name := range host {
+			if inherit.allows(name) {
+				optional.Add(name)
+			}
+		}
+		return optional
+	}
+
+	// The prompts are asked in order, so only the earlier answers can be referenced.
+	for k, prompt := range p.Spec.Prompts {
+		var fields, paths []string
+		for _, field := range []struct {
+			name string
+			text LocaleText
+		}{{"message", prompt.Message}, {"default", NewLocaleText(prompt.Default)}, {"help", prompt.Help}} {
+			field.text.texts(func(key, text string) {
+				fields = append(fields, text)
+				if len(key) > 0 {
+					paths = append(paths, field.name+"."+key)
+				} else {
+					paths = append(paths, field.name)
+				}
+			})
+		}
+		for i, item := range prompt.Enum {
+			fields = append(fields, item)
+			paths = append(paths, fmt.Sprintf("enum[%d]", i))
+		}
+		if prompt.EnumFrom != nil {
+			fields = append(fields, prompt.EnumFrom.File)
+			paths = append(paths, "enumFrom.file")
+
+			path := fmt.Sprintf("spec.prompts[%d].enumFrom.command", k)
+			names, optional := commandVars(prompt.EnumFrom.Command)
+			reference(path, "", names, inherited(p.Spec.Inherit, optional))
+		}
+		for i, text := range fields {
+			if !isTemplate(text) {
+				continue
+			}
+			path := fmt.Sprintf("spec.prompts[%d].%s", k, paths[i])
+			names, err := templateVars(paths[i], text)
+			if err != nil {
+				errs = append(errs, &FieldError{Path: path, Message: err.Error()})
+			}
+			reference(path, "", names, nil)
+		}
+		defined.Add(prompt.Name)
+	}
+
+	// The vars are evaluated in order, so only the earlier vars can be referenced.
+	for k, v := range p.Spec.Vars {
+		path := fmt.Sprintf("spec.vars[%d].value", k)
+		names, err := templateVars(v.Name, v.Value)
+		if err != nil {
+			errs = append(errs, &FieldError{Path: path, Message: err.Error()})
+		}
+		reference(path, "", names, nil)
+		defined.Add(v.Name)
+	}
+
+	for k, step := range p.Spec.Steps {
+		stepPath := fmt.Sprintf("spec.steps[%d]", k)
+		// The secrets are only exported to the command and its env if the step declares them.
+		undeclaredSecrets := func(path string, names []string) {
+			exported := sets.NewString(step.Secrets...)
+			for _, name := range names {
+				if secrets.Has(name) && !exported.Has(name) {
+					errs = append(errs, &FieldError{
+						Path:    path,
+						Message: fmt.Sprintf("secret %q is not exported to the command, declare it in secrets", name),
+						Warning: true,
+					})
+					exported.Add(name)
+				}
+			}
+		}
+		// The outputs are registered before the step is executed,
+		// and are available to the step itself and the subsequent steps.
+		for _, name := range step.Outputs() {
+			defined.Add(name)
+		}
+		if len(step.Name) > 0 {
+			for _, name := range step.nestedOutputs(0) {
+				defined.Add(step.Name + "_" + name)
+			}
+		}
+		if step.Render != nil {
+			err := step.Render.walkTemplates(func(name, text string) error {
+				names, err := templateVars(name, text)
+				if err != nil {
+					errs = append(errs, &FieldError{Path: stepPath + ".render.src", Message: err.Error()})
+					return nil
+				}
+				reference(stepPath+".render.src", name+": ", names, nil)
+				return nil
+			})
+			// The missing source is already reported by validate.
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, &FieldError{Path: stepPath + ".render.src", Message: err.Error()})
+			}
+
+			names, err := templateVars("dest", step.Render.Dest)
+			if err != nil {
+				errs = append(errs, &FieldError{Path: stepPath + ".render.dest", Message: err.Error()})
+			}
+			reference(stepPath+".render.dest", "", names, nil)
+		}
+		if len(step.With) > 0 {
+			walkParams(stepPath+".with", step.With, func(path, text string) {
+				names, err := templateVars(path, text)
+				if err != nil {
+					errs = append(errs, &FieldError{Path: path, Message: err.Error()})
+					return
+				}
+				reference(path, "", names, nil)
+			})
+		}
+		for _, name := range sortedKeys(step.Env) {
+			path := stepPath + ".env." + name
+			names, err := templateVars(name, step.Env[name])
+			if err != nil {
+				errs = append(errs, &FieldError{Path: path, Message: err.Error()})
+			}
+			reference(path, "", names, nil)
+			undeclaredSecrets(path, names)
+		}
+		if step.ForEach != nil {
+			if len(step.ForEach.Items) == 0 {
+				reference(stepPath+".forEach", "", []string{step.ForEach.Var}, nil)
+			}
+			for i, item := range step.ForEach.Items {
+				path := fmt.Sprintf("%s.forEach[%d]", stepPath, i)
+				names, err := templateVars(path, item)
+				if err != nil {
+					errs = append(errs, &FieldError{Path: path, Message: err.Error()})
+				}
+				reference(path, "", names, nil)
+			}
+		}
+		if len(step.Workdir) > 0 {
+			names, err := templateVars("workdir", step.Workdir)
+			if err != nil {
+				errs = append(errs, &FieldError{Path: stepPath + ".workdir", Message: err.Error()})
+			}
+			reference(stepPath+".workdir", "", names, nil)
+		}
+		// The variables can only be found in the inline scripts of sh and bash.
+		if script, field := step.inlineScript(); len(field) > 0 && step.posixShell() {
+			names, optional := commandVars(script)
+			// The env of the step is defined for the command only.
+			for name := range step.Env {
+				optional.Add(name)
+			}
+			if step.ForEach != nil {
+				optional.Add(ItemEnvName)
+			}
+			inherit := p.Spec.Inherit
+			if step.Inherit != nil {
+				inherit = step.Inherit
+			}
+			reference(stepPath+"."+field, "", names, inherited(inherit, optional))
+			undeclaredSecrets(stepPath+"."+field, names)
+		}
+	}
+
+	for k, v := range p.Spec.Vars {
+		if !used.Has(v.Name) {
+			used.Add(v.Name)
+			errs = append(errs, &FieldError{
+				Path:    fmt.Sprintf("spec.vars[%d].name", k),
+				Message: fmt.Sprintf("var %q is never used", v.Name),
+				Warning: true,
+			})
+		}
+	}
+	for k, prompt := range p.Spec.Prompts {
+		if !used.Has(prompt.Name) {
+			// Report only once for the duplicate prompts.
+			used.Add(prompt.Name)
+			errs = append(errs, &FieldError{
+				Path:    fmt.Sprintf("spec.prompts[%d].name", k),
+				Message: fmt.Sprintf("prompt %q is never used", prompt.Name),
+				Warning: true,
+			})
+		}
+	}
+	return errs
+}
+
+// walkParams calls fn with the path and the value of each string in the params.
+func walkParams(path string, value interface{}, fn func(path, text string)) {
+	switch val := value.(type) {
+	case string:
+		fn(path, val)
+	case []interface{}:
+		for i, item := range val {
+			walkParams(fmt.Sprintf("%s[%d]", path, i), item, fn)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkParams(joinFieldPath(path, k), val[k], fn)
+		}
+	}
+}
+
+// walkTemplates calls fn with the name and the content of each template to be rendered,
+// including the templated paths of the entries in the src directory.
+func (r *SpecStepRender) walkTemplates(fn func(name, text string) error) error {
+	var (
+		stat fs.FileInfo
+		err  error
+	)
+	if r.fsys != nil {
+		stat, err = fs.Stat(r.fsys, r.Src)
+	} else {
+		stat, err = os.Stat(r.Src)
+	}
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		if matchGlobs(r.Raw, path.Base(filepath.ToSlash(r.Src))) {
+			return nil
+		}
+		var b []byte
+		if r.fsys != nil {
+			b, err = fs.ReadFile(r.fsys, r.Src)
+		} else {
+			b, err = os.ReadFile(r.Src)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(r.Src, string(b))
+	}
+
+	fsys, err := r.dirFS()
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if matchGlobs(r.Exclude, name) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if err := fn(path.Join(r.Src, name)+" (path)", name); err != nil {
+			return err
+		}
+		if d.IsDir() || (len(r.Include) > 0 && !matchGlobs(r.Include, name)) || matchGlobs(r.Raw, name) {
+			return nil
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return fn(path.Join(r.Src, name), string(b))
+	})
+}
+
+// templateVars returns the names of the variables referenced by `.env.<name>`
+// or `index .env "<name>"` in the template.
+func templateVars(name, text string) ([]string, error) {
+	t, err := parseTemplate(name, text)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, v := range n.Nodes {
+				walk(v)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(&n.BranchNode)
+		case *parse.RangeNode:
+			walk(&n.BranchNode)
+		case *parse.WithNode:
+			walk(&n.BranchNode)
+		case *parse.BranchNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) == 3 {
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "index" {
+					field, ok1 := n.Args[1].(*parse.FieldNode)
+					key, ok2 := n.Args[2].(*parse.StringNode)
+					if ok1 && ok2 && len(field.Ident) == 1 && field.Ident[0] == "env" {
+						names = append(names, key.Text)
+						return
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "env" {
+				names = append(names, n.Ident[1])
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "env" {
+				names = append(names, n.Ident[2])
+			}
+		}
+	}
+	for _, v := range t.Templates() {
+		if v.Tree != nil {
+			walk(v.Tree.Root)
+		}
+	}
+	return names, nil
+}
+
+// commandVars returns the names of the variables referenced by the command except
+// for the escaped ones, and the names which need not be defined by the pipeline,
+// i.e. the ones with default values and the ones assigned by the command itself.
+func commandVars(command string) ([]string, sets.String) {
+	optional := sets.NewString()
+	for _, parts := range commandAssignRegexp.FindAllStringSubmatch(command, -1) {
+		optional.Add(parts[1], parts[2])
+	}
+
+	var names []string
+	for _, parts := range commandVarRegexp.FindAllStringSubmatch(command, -1) {
+		if len(parts[1]) > 0 {
+			continue
+		}
+		name := parts[2] + parts[4]
+		// ${name:-default} and the like handle the missing variable by themselves.
+		if strings.ContainsAny(parts[3], "-=?+") {
+			optional.Add(name)
+		}
+		names = append(names, name)
+	}
+	return names, optional
+}
@@ -0,0 +1,55 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPipeline_Lint(t *testing.T) {
+	t.Setenv("AIDE_LINT_HOST", "x")
+	p := NewPipeline("test")
+	p.Metadata.Labels = map[string]string{"project": "aide"}
+	p.AddInputPrompt("custom_name", "What's your name?", "", "")
+	p.AddInputPrompt("unused", "Unused?", "", "")
+	fsys := fstest.MapFS{
+		"test.in": {Data: []byte("{{ .env.custom_name }} {{ .env.step1_dest }} {{ .env.AIDE_LINT_HOST }}")},
+	}
+	p.AddStep("step1", NewEmbedStepRender(fsys, "test.in", "{{.env.custm_name}}/test.out"), "")
+	p.AddStep("", nil, `echo $custom_name $project $step1_dest ${custom_nmae} \$escaped ${optional:-x}`)
+	p.AddStep("", nil, `for f in a b; do echo $f; done; x=1; echo $x $custom_nmae`)
+	// The variables of the host are only known by the commands which inherit them.
+	p.AddStep("", nil, `echo $AIDE_LINT_HOST`)
+	p.AddStep("", nil, `echo $AIDE_LINT_HOST`)
+	p.Spec.Steps[4].Inherit = &EnvInherit{Mode: InheritNone}
+
+	var got []string
+	for _, e := range p.Lint() {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		`spec.steps[0].render.src: warning: test.in: undefined variable "AIDE_LINT_HOST"`,
+		`spec.steps[0].render.dest: warning: undefined variable "custm_name"`,
+		`spec.steps[1].command: warning: undefined variable "custom_nmae"`,
+		`spec.steps[2].command: warning: undefined variable "custom_nmae"`,
+		`spec.steps[4].command: warning: undefined variable "AIDE_LINT_HOST"`,
+		`spec.prompts[1].name: warning: prompt "unused" is never used`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %#v, want %#v", got, want)
+	}
+}
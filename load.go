@@ -0,0 +1,103 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadPipeline reads the pipeline named by name from fsys,
+// the relative render sources are resolved against the directory of name in fsys.
+// It is usually used with embed.FS to build an installer.
+func LoadPipeline(fsys fs.FS, name string) (*Pipeline, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePipeline(data, fsys, path.Dir(name))
+}
+
+// LoadPipelineFile reads the pipeline from the local file,
+// the relative render sources are resolved against the directory of the file.
+func LoadPipelineFile(name string) (*Pipeline, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePipeline(data, nil, filepath.Dir(name))
+}
+
+// ParsePipeline parses the pipeline from data strictly, the fields which are not
+// defined in the PipelineSchema are rejected.
+// The relative render sources are resolved against dir, which is a directory in fsys,
+// or in the local filesystem when fsys is nil.
+// If dir is empty, the relative render sources are kept as they are.
+func ParsePipeline(data []byte, fsys fs.FS, dir string) (*Pipeline, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if errs := PipelineSchema().ValidateNode(&node, ""); len(errs) > 0 {
+		sortFieldErrors(errs)
+		return nil, errs
+	}
+
+	var pipeline Pipeline
+	if err := node.Decode(&pipeline); err != nil {
+		return nil, err
+	}
+	pipeline.resolve(fsys, dir)
+	return &pipeline, nil
+}
+
+// resolve binds the render steps, the script files and the nested pipelines to fsys,
+// and resolves their relative paths against dir.
+func (p *Pipeline) resolve(fsys fs.FS, dir string) {
+	for k := range p.Spec.Steps {
+		step := &p.Spec.Steps[k]
+		if step.Render != nil && step.Render.fsys == nil {
+			step.Render.fsys, step.Render.Src = resolveSource(fsys, dir, step.Render.Src)
+		}
+		if len(step.ScriptFile) > 0 && step.fsys == nil {
+			step.fsys, step.ScriptFile = resolveSource(fsys, dir, step.ScriptFile)
+		}
+		if file, ok := step.With["file"].(string); ok && step.Uses == PipelineStepType && !isTemplate(file) {
+			step.fsys, step.With["file"] = resolveSource(fsys, dir, file)
+		}
+	}
+}
+
+// resolveSource returns the filesystem and the path of src which is relative to dir.
+func resolveSource(fsys fs.FS, dir, src string) (fs.FS, string) {
+	if fsys == nil {
+		if len(dir) > 0 && !filepath.IsAbs(src) {
+			src = filepath.Join(dir, src)
+		}
+		return nil, src
+	}
+	// Absolute sources always refer to the local filesystem.
+	if filepath.IsAbs(src) {
+		return nil, src
+	}
+	if len(dir) > 0 {
+		src = path.Join(dir, filepath.ToSlash(src))
+	}
+	return fsys, src
+}
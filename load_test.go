@@ -0,0 +1,115 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+const loadTestPipeline = `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: load
+spec:
+  steps:
+    - name: config
+      render:
+        src: tmpl/config.in
+        dest: /tmp/config
+    - name: install
+      scriptFile: scripts/install.sh
+    - name: abs
+      scriptFile: /opt/install.sh
+`
+
+func TestLoadPipeline(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pipeline.yaml":                 {Data: []byte(loadTestPipeline)},
+		"resource/pipeline.yaml":        {Data: []byte(loadTestPipeline)},
+		"resource/tmpl/config.in":       {Data: []byte("config")},
+		"resource/scripts/install.sh":   {Data: []byte("true")},
+		"resource/invalid.yaml":         {Data: []byte("kind: [")},
+		"resource/unknown-field.yaml":   {Data: []byte("apiVersion: v1\nkind: Pipeline\nmetadata: {name: x}\nspec: {step: []}\n")},
+		"resource/nested/pipeline.yaml": {Data: []byte(loadTestPipeline)},
+	}
+	tests := []struct {
+		name       string
+		file       string
+		wantSrc    string
+		wantScript string
+		wantErr    bool
+	}{
+		{name: "case 1: root directory", file: "pipeline.yaml", wantSrc: "tmpl/config.in", wantScript: "scripts/install.sh"},
+		{name: "case 2: sub directory", file: "resource/pipeline.yaml", wantSrc: "resource/tmpl/config.in", wantScript: "resource/scripts/install.sh"},
+		{name: "case 3: nested directory", file: "resource/nested/pipeline.yaml", wantSrc: "resource/nested/tmpl/config.in", wantScript: "resource/nested/scripts/install.sh"},
+		{name: "case 4: missing file", file: "missing.yaml", wantErr: true},
+		{name: "case 5: invalid yaml", file: "resource/invalid.yaml", wantErr: true},
+		{name: "case 6: unknown field", file: "resource/unknown-field.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LoadPipeline(fsys, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			render := p.Spec.Steps[0].Render
+			if render.Src != tt.wantSrc || render.fsys == nil {
+				t.Errorf("render.src = %s, want %s bound to fsys", render.Src, tt.wantSrc)
+			}
+			script := p.Spec.Steps[1]
+			if script.ScriptFile != tt.wantScript || script.fsys == nil {
+				t.Errorf("scriptFile = %s, want %s bound to fsys", script.ScriptFile, tt.wantScript)
+			}
+			// The absolute paths always refer to the local filesystem.
+			if abs := p.Spec.Steps[2]; abs.ScriptFile != "/opt/install.sh" || abs.fsys != nil {
+				t.Errorf("absolute scriptFile = %s, bound to fsys %v", abs.ScriptFile, abs.fsys != nil)
+			}
+		})
+	}
+}
+
+func TestLoadPipelineFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pipeline.yaml")
+	if err := os.WriteFile(file, []byte(loadTestPipeline), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPipelineFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Spec.Steps[0].Render.Src, filepath.Join(dir, "tmpl/config.in"); got != want {
+		t.Errorf("render.src = %s, want %s", got, want)
+	}
+	if got, want := p.Spec.Steps[1].ScriptFile, filepath.Join(dir, "scripts/install.sh"); got != want {
+		t.Errorf("scriptFile = %s, want %s", got, want)
+	}
+
+	// The relative paths are kept as they are without the directory.
+	p, err = ParsePipeline([]byte(loadTestPipeline), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Spec.Steps[0].Render.Src; got != "tmpl/config.in" {
+		t.Errorf("render.src = %s, want tmpl/config.in", got)
+	}
+}
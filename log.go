@@ -17,11 +17,19 @@
 package aide
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
+
+	"github.com/zc2638/aide/stage"
 )
 
 type LogInterface interface {
@@ -30,6 +38,21 @@ type LogInterface interface {
 	Writer() io.Writer
 }
 
+// ContextLogInterface is implemented by the loggers which record the stage and step
+// from the context, the context carries them by stage.NameKey and StepCtxKey.
+type ContextLogInterface interface {
+	LogInterface
+	WithContext(ctx context.Context) LogInterface
+}
+
+// withContext returns the logger bound to ctx if it supports.
+func withContext(logger LogInterface, ctx context.Context) LogInterface {
+	if l, ok := logger.(ContextLogInterface); ok && ctx != nil {
+		return l.WithContext(ctx)
+	}
+	return logger
+}
+
 type LogLevel int
 
 const (
@@ -102,6 +125,119 @@ func standardMessage(s string) string {
 	return s
 }
 
+func (l LogLevel) String() string {
+	switch l {
+	case ErrorLevel:
+		return "error"
+	case WarnLevel:
+		return "warn"
+	default:
+		return "info"
+	}
+}
+
+var _ ContextLogInterface = (*jsonLog)(nil)
+
+// jsonLog writes one JSON object per line for each log.
+type jsonLog struct {
+	mu  *sync.Mutex
+	out io.Writer
+	ctx context.Context
+}
+
+type jsonLogEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Stage   string `json:"stage,omitempty"`
+	Step    string `json:"step,omitempty"`
+	StepNum int    `json:"stepNum,omitempty"`
+	Message string `json:"msg"`
+}
+
+// NewJSONLog returns a logger which writes one JSON object per line to out,
+// with the timestamp, level, stage name, step name, step number and message.
+func NewJSONLog(out io.Writer) LogInterface {
+	return &jsonLog{mu: &sync.Mutex{}, out: out}
+}
+
+func (l *jsonLog) WithContext(ctx context.Context) LogInterface {
+	return &jsonLog{mu: l.mu, out: l.out, ctx: ctx}
+}
+
+func (l *jsonLog) Writer() io.Writer {
+	return &lineWriter{log: func(line string) { l.Log(InfoLevel, line) }}
+}
+
+func (l *jsonLog) Log(level LogLevel, args ...interface{}) {
+	l.write(level, fmt.Sprint(args...))
+}
+
+func (l *jsonLog) Logf(level LogLevel, format string, args ...interface{}) {
+	l.write(level, fmt.Sprintf(format, args...))
+}
+
+func (l *jsonLog) write(level LogLevel, message string) {
+	entry := jsonLogEntry{
+		Time:    time.Now().Format(time.RFC3339Nano),
+		Level:   level.String(),
+		Message: strings.TrimRight(message, "\n"),
+	}
+	if l.ctx != nil {
+		if stepCtx, ok := l.ctx.Value(StepCtxKey).(*StepContext); ok && stepCtx != nil {
+			entry.Stage = stepCtx.StageName()
+			entry.Step = stepCtx.StepName()
+			entry.StepNum = stepCtx.StepNum()
+		} else if name, ok := l.ctx.Value(stage.NameKey).(string); ok {
+			entry.Stage = name
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(entry); err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+// lineWriter calls log with each line written.
+type lineWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	log func(line string)
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(b)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := w.buf.Next(i + 1)
+		w.log(string(line[:len(line)-1]))
+	}
+	return len(b), nil
+}
+
+// Flush logs the remaining data which does not end with a newline.
+func (w *lineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.log(w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
+
 type emptyWriter struct{}
 
 func (w *emptyWriter) Write(b []byte) (int, error) {
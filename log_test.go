@@ -0,0 +1,64 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	ins := New(WithLogOption(NewJSONLog(&buf)))
+	ins.AddStages(NewStage("build").AddSteps(
+		StepFunc(func(sc *StepContext) { sc.Log("hello") }).Step("greet"),
+		StepFunc(func(sc *StepContext) { sc.ErrorStr("failed") }).Step("fail"),
+	))
+	if err := ins.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail")
+	}
+
+	var entries []jsonLogEntry
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry jsonLogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Time == "" {
+			t.Errorf("entry %+v has no time", entry)
+		}
+		entry.Time = ""
+		entries = append(entries, entry)
+	}
+
+	want := []jsonLogEntry{
+		{Level: "info", Stage: "build", Message: "[+] STAGE build"},
+		{Level: "info", Stage: "build", Step: "greet", StepNum: 1, Message: "=> greet"},
+		{Level: "info", Stage: "build", Step: "greet", StepNum: 1, Message: "hello"},
+		{Level: "info", Stage: "build", Step: "fail", StepNum: 2, Message: "=> fail"},
+		{Level: "error", Stage: "build", Step: "fail", StepNum: 2, Message: "Failed"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
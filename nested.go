@@ -0,0 +1,152 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// PipelineStepType is the built-in step type which runs another pipeline.
+// The relative file of the nested pipeline is resolved like the render sources.
+const PipelineStepType = "pipeline"
+
+// maxNestedDepth limits the depth of the nested pipelines, which guards against the cycles.
+const maxNestedDepth = 8
+
+func init() {
+	RegisterStepType(PipelineStepType, func() StepExecutor { return &pipelineStep{} })
+}
+
+// NestedStepError is returned by the pipeline step when the nested pipeline fails.
+type NestedStepError struct {
+	// Path is the names of the steps from the pipeline step to the failed step
+	// of the innermost pipeline.
+	Path []string
+	Err  error
+}
+
+func (e *NestedStepError) Error() string {
+	return fmt.Sprintf("step %s failed: %v", strings.Join(e.Path, " > "), e.Err)
+}
+
+func (e *NestedStepError) Unwrap() error { return e.Err }
+
+type nestedDepthKey struct{}
+
+// pipelineStep runs the nested pipeline with the answers mapped from the parent,
+// the outputs registered by the nested pipeline are registered as the outputs of the step.
+type pipelineStep struct {
+	File string `yaml:"file"`
+	// Interactive asks the prompts of the nested pipeline which are not answered,
+	// otherwise their defaults are used.
+	Interactive bool              `yaml:"interactive"`
+	Answers     map[string]string `yaml:"answers"`
+}
+
+func (s *pipelineStep) Execute(ctx context.Context, in *StepInput) error {
+	depth, _ := ctx.Value(nestedDepthKey{}).(int)
+	if depth >= maxNestedDepth {
+		return fmt.Errorf("pipelines are nested deeper than %d", maxNestedDepth)
+	}
+	if len(s.File) == 0 {
+		return errors.New("file is required")
+	}
+	child, err := loadNestedPipeline(in.fsys, s.File)
+	if err != nil {
+		return err
+	}
+	if err := child.SetValues(s.Answers); err != nil {
+		return err
+	}
+	if err := child.Validate(); err != nil {
+		return fmt.Errorf("invalid pipeline %s: %v", s.File, err)
+	}
+	child.skipPrompt = !s.Interactive
+	if parent := in.parent; parent != nil {
+		child.skipPrompt = child.skipPrompt || parent.skipPrompt
+		child.pluginDir = parent.pluginDir
+		child.lang = parent.lang
+	}
+	child.SetLogger(in.Logger)
+
+	ctx = context.WithValue(ctx, nestedDepthKey{}, depth+1)
+	if err := child.Execute(ctx); err != nil {
+		return nestedError(in.run, child, err)
+	}
+	for name, value := range child.Outputs() {
+		in.SetOutput(name, value)
+	}
+	return nil
+}
+
+// loadNestedPipeline loads the nested pipeline from fsys, or from the local filesystem if fsys is nil.
+func loadNestedPipeline(fsys fs.FS, file string) (*Pipeline, error) {
+	if fsys != nil {
+		return LoadPipeline(fsys, file)
+	}
+	return LoadPipelineFile(file)
+}
+
+// nestedError returns the error of the nested pipeline with the path of the failed step,
+// the paths of the deeper pipelines are joined into a single path.
+func nestedError(name string, child *Pipeline, err error) error {
+	path := []string{name}
+	var nested *NestedStepError
+	if errors.As(err, &nested) {
+		return &NestedStepError{
+			Path: append(path, nested.Path...),
+			Err:  child.redactError(nested.Err),
+		}
+	}
+	if report := child.Report(); report != nil {
+		for _, stage := range report.Stages {
+			for _, step := range stage.Steps {
+				if step.Status == StatusFailed {
+					return &NestedStepError{Path: append(path, step.Name), Err: err}
+				}
+			}
+		}
+	}
+	return &NestedStepError{Path: path, Err: err}
+}
+
+// nestedOutputs returns the names of the outputs which the nested pipeline of the step
+// with a static file registers, it returns nil if the pipeline is unable to be loaded.
+func (s *SpecStep) nestedOutputs(depth int) []string {
+	file, ok := s.With["file"].(string)
+	if s.Uses != PipelineStepType || !ok || len(file) == 0 || isTemplate(file) || depth >= maxNestedDepth {
+		return nil
+	}
+	child, err := loadNestedPipeline(s.fsys, file)
+	if err != nil {
+		return nil
+	}
+	var outputs []string
+	for k := range child.Spec.Steps {
+		step := &child.Spec.Steps[k]
+		outputs = append(outputs, step.Outputs()...)
+		if len(step.Name) == 0 {
+			continue
+		}
+		for _, name := range step.nestedOutputs(depth + 1) {
+			outputs = append(outputs, step.Name+"_"+name)
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,155 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPipeline_nested(t *testing.T) {
+	dir := t.TempDir()
+	parent := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: platform
+spec:
+  prompts:
+    - name: app
+      type: Input
+      default: demo
+  steps:
+    - name: database
+      uses: pipeline
+      with:
+        file: components/database/pipeline.yaml
+        answers:
+          db_name: "{{ .env.app }}_db"
+    - name: check
+      command: test "$database_init_dest" = "` + dir + `/db.conf" && test "$database_schema_migrate_path" = "` + dir + `/v2"
+`
+	database := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: database
+spec:
+  prompts:
+    - name: db_name
+      type: Input
+    - name: db_port
+      type: Input
+      default: "3306"
+  steps:
+    - name: init
+      render:
+        src: db.conf
+        dest: "` + dir + `/db.conf"
+    - name: schema
+      uses: pipeline
+      with:
+        file: schema.yaml
+`
+	schema := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: schema
+spec:
+  steps:
+    - name: migrate
+      uses: test-write-file
+      with:
+        path: ` + dir + `/v2
+        content: migrated
+        mode: 0644
+`
+	fsys := fstest.MapFS{
+		"resource/pipeline.yaml":                     {Data: []byte(parent)},
+		"resource/components/database/pipeline.yaml": {Data: []byte(database)},
+		"resource/components/database/db.conf":       {Data: []byte("name={{ .env.db_name }}:{{ .env.db_port }}")},
+		"resource/components/database/schema.yaml":   {Data: []byte(schema)},
+	}
+	p, err := LoadPipeline(fsys, "resource/pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if errs := p.Lint(); len(errs) > 0 {
+		t.Errorf("Lint() = %v", errs)
+	}
+
+	p.skipPrompt = true
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "db.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "name=demo_db:3306" {
+		t.Errorf("content = %q", b)
+	}
+	want := map[string]string{
+		"database_init_src":            "resource/components/database/db.conf",
+		"database_init_dest":           dir + "/db.conf",
+		"database_schema_migrate_path": dir + "/v2",
+	}
+	if got := p.Outputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Outputs() = %v, want %v", got, want)
+	}
+
+	// The errors show the path of the failed step in the nested pipelines.
+	fsys["resource/components/database/schema.yaml"] = &fstest.MapFile{
+		Data: []byte(strings.Replace(schema, "/v2", "/missing/v2", 1)),
+	}
+	p, err = LoadPipeline(fsys, "resource/pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.skipPrompt = true
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	err = p.Execute(context.Background())
+	var nested *NestedStepError
+	if !errors.As(err, &nested) {
+		t.Fatalf("Execute() error = %v, want NestedStepError", err)
+	}
+	if path := []string{"database", "schema", "migrate"}; !reflect.DeepEqual(nested.Path, path) {
+		t.Errorf("error path = %v, want %v", nested.Path, path)
+	}
+	if p.Outputs() != nil {
+		t.Errorf("Outputs() = %v after the failure", p.Outputs())
+	}
+
+	p.Spec.Steps[0].With["answers"] = map[string]interface{}{"unknown": "x"}
+	if err := p.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "prompt unknown is not defined") {
+		t.Errorf("Execute() error = %v, want unknown prompt error", err)
+	}
+
+	p.Spec.Steps[0].With["file"] = "resource/missing.yaml"
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "spec.steps[0].with.file") {
+		t.Errorf("Validate() error = %v, want missing file error", err)
+	}
+}
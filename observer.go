@@ -0,0 +1,61 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import "time"
+
+// EventType defines the type of the lifecycle events.
+type EventType string
+
+const (
+	EventStageStarted  EventType = "StageStarted"
+	EventStageFinished EventType = "StageFinished"
+	EventStageSkipped  EventType = "StageSkipped"
+	EventStepStarted   EventType = "StepStarted"
+	EventStepFinished  EventType = "StepFinished"
+	EventStepSkipped   EventType = "StepSkipped"
+	EventRunFinished   EventType = "RunFinished"
+)
+
+// Event defines a lifecycle event of the stages and steps.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Stage is the name of the stage, it is empty for EventRunFinished.
+	Stage string
+	// Step is the name of the step, it is only set for the step events.
+	Step string
+	// Num is the number of the step in the stage, starting from 1.
+	Num int
+	// Total is the total number of the steps in the stage.
+	Total int
+	// Err is the error of the finished events, it is nil if succeeded.
+	Err error
+	// Duration is the duration of the finished events.
+	Duration time.Duration
+}
+
+// Observer receives the lifecycle events, e.g. for metrics, UIs and audit logs.
+// Observe is called synchronously and can be called concurrently by the async stages.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as Observer.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
@@ -0,0 +1,111 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordObserver) Observe(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := string(e.Type)
+	if e.Stage != "" {
+		s += " " + e.Stage
+	}
+	if e.Step != "" {
+		s += fmt.Sprintf(" %s[%d/%d]", e.Step, e.Num, e.Total)
+	}
+	if e.Err != nil {
+		s += " err=" + e.Err.Error()
+	}
+	o.events = append(o.events, s)
+}
+
+func (o *recordObserver) String() string {
+	return strings.Join(o.events, "\n")
+}
+
+func TestInstance_Observer(t *testing.T) {
+	o := &recordObserver{}
+	ins := New(WithVerboseOption(false), WithObserverOption(o))
+	ins.AddStages(
+		NewStage("first").AddSteps(
+			StepFunc(func(sc *StepContext) {}).Step("ok"),
+			StepFunc(func(sc *StepContext) {}).Step("next"),
+		),
+		NewStage("skipped").Skip(true).AddSteps(
+			StepFunc(func(sc *StepContext) {}).Step("unreachable"),
+		),
+		NewStage("second").AddSteps(
+			StepFunc(func(sc *StepContext) { sc.ErrorStr("failed") }).Step("failed"),
+		),
+	)
+	if err := ins.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail")
+	}
+
+	want := strings.Join([]string{
+		"StageStarted first",
+		"StepStarted first ok[1/2]",
+		"StepFinished first ok[1/2]",
+		"StepStarted first next[2/2]",
+		"StepFinished first next[2/2]",
+		"StageFinished first",
+		"StageSkipped skipped",
+		"StageStarted second",
+		"StepStarted second failed[1/1]",
+		"StepFinished second failed[1/1] err=failed",
+		"StageFinished second err=failed",
+		"RunFinished err=failed",
+	}, "\n")
+	if got := o.String(); got != want {
+		t.Errorf("events:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPipeline_Observer(t *testing.T) {
+	o := &recordObserver{}
+	p := NewPipeline("demo")
+	p.AddStep("ok", nil, "true")
+	p.AddStep("fail", nil, "exit 3")
+	p.SetStateDir(t.TempDir())
+	p.AddObserver(o)
+	if err := p.Execute(context.Background()); err == nil {
+		t.Fatal("Execute() should fail")
+	}
+
+	want := strings.Join([]string{
+		"StageStarted demo",
+		"StepStarted demo ok[1/2]",
+		"StepFinished demo ok[1/2]",
+		"StepStarted demo fail[2/2]",
+		"StepFinished demo fail[2/2] err=run command (exit 3) failed: exit status 3",
+		"StageFinished demo err=run command (exit 3) failed: exit status 3",
+		"RunFinished err=run command (exit 3) failed: exit status 3",
+	}, "\n")
+	if got := o.String(); got != want {
+		t.Errorf("events:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PluginProtocolVersion is the version of the protocol between aide and the plugins.
+const PluginProtocolVersion = "v1"
+
+// PluginPrefix is the prefix of the names of the plugin executables,
+// the step with `uses: foo` runs the executable named aide-plugin-foo.
+const PluginPrefix = "aide-plugin-"
+
+// PluginRequest is written to the stdin of the plugin as JSON.
+type PluginRequest struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	// Step is the name of the step, it can be empty.
+	Step string `json:"step"`
+	// Uses is the step type which references the plugin.
+	Uses string `json:"uses"`
+	// Params defines the params in `with` of the step, the templates have been rendered.
+	Params map[string]interface{} `json:"params"`
+	// Vars defines the variables of the pipeline, the secrets are excluded unless the step declares them.
+	Vars     map[string]string `json:"vars"`
+	Pipeline Metadata          `json:"pipeline"`
+}
+
+// PluginStatus defines the status of the plugin response.
+type PluginStatus string
+
+const (
+	PluginStatusOK     PluginStatus = "ok"
+	PluginStatusFailed PluginStatus = "failed"
+)
+
+// PluginResponse is read from the stdout of the plugin as JSON.
+type PluginResponse struct {
+	ProtocolVersion string       `json:"protocolVersion"`
+	Status          PluginStatus `json:"status"`
+	// Outputs are registered as the outputs of the step.
+	Outputs map[string]string `json:"outputs"`
+	// Messages are written to the logger of the step.
+	Messages []PluginMessage `json:"messages"`
+	// Error describes the reason if the status is failed.
+	Error string `json:"error"`
+}
+
+// PluginMessage defines a message logged by the plugin.
+type PluginMessage struct {
+	// Level is one of info, warn and error, it defaults to info.
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// DefaultPluginDir returns the default directory to look up the plugins.
+func DefaultPluginDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aide", "plugins")
+	}
+	return filepath.Join(home, ".aide", "plugins")
+}
+
+// SetPluginDir sets the directory to look up the plugins before PATH, only PATH is used if empty.
+func (p *Pipeline) SetPluginDir(dir string) {
+	p.pluginDir = dir
+}
+
+// lookupPlugin returns the path of the plugin executable of the step type.
+func (p *Pipeline) lookupPlugin(uses string) (string, error) {
+	name := PluginPrefix + uses
+	if len(p.pluginDir) > 0 {
+		if path, err := exec.LookPath(filepath.Join(p.pluginDir, name)); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// stepFactory returns the factory of the step type, the registered step types
+// take precedence over the plugins.
+func (p *Pipeline) stepFactory(uses string) (StepFactory, error) {
+	if factory, ok := lookupStepType(uses); ok {
+		return factory, nil
+	}
+	path, err := p.lookupPlugin(uses)
+	if err != nil {
+		return nil, fmt.Errorf("unknown step type %q, it is neither registered nor found as plugin %s%s", uses, PluginPrefix, uses)
+	}
+	return func() StepExecutor {
+		return &pluginStep{path: path, uses: uses, metadata: p.Metadata}
+	}, nil
+}
+
+// pluginStep executes the plugin executable with the JSON protocol.
+type pluginStep struct {
+	path     string
+	uses     string
+	metadata Metadata
+	params   map[string]interface{}
+}
+
+// UnmarshalYAML accepts any params, they are passed to the plugin as they are.
+func (s *pluginStep) UnmarshalYAML(node *yaml.Node) error {
+	return node.Decode(&s.params)
+}
+
+func (s *pluginStep) Execute(ctx context.Context, in *StepInput) error {
+	req := &PluginRequest{
+		ProtocolVersion: PluginProtocolVersion,
+		Step:            in.Name,
+		Uses:            s.uses,
+		Params:          s.params,
+		Vars:            in.Vars,
+		Pipeline:        s.metadata,
+	}
+	if req.Params == nil {
+		req.Params = map[string]interface{}{}
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	stderr := &lineWriter{log: func(line string) { in.Logger.Log(InfoLevel, line) }}
+	cmd := exec.CommandContext(ctx, s.path)
+	// The plugin inherits the host variables by the policy of the pipeline and
+	// the variables exported to the commands, the undeclared secrets are excluded.
+	cmd.Env = envToSlice(in.env)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+	_ = stderr.Flush()
+
+	var resp PluginResponse
+	if stdout.Len() > 0 {
+		if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+			if runErr != nil {
+				return fmt.Errorf("plugin %s failed: %w", s.path, runErr)
+			}
+			return fmt.Errorf("plugin %s returned an invalid response: %v", s.path, err)
+		}
+	}
+	for _, msg := range resp.Messages {
+		in.Logger.Log(pluginLogLevel(msg.Level), msg.Message)
+	}
+	if runErr != nil {
+		if len(resp.Error) > 0 {
+			return fmt.Errorf("plugin %s failed: %s: %w", s.path, resp.Error, runErr)
+		}
+		return fmt.Errorf("plugin %s failed: %w", s.path, runErr)
+	}
+	if stdout.Len() == 0 {
+		return fmt.Errorf("plugin %s returned no response", s.path)
+	}
+	if resp.ProtocolVersion != PluginProtocolVersion {
+		return fmt.Errorf("plugin %s uses protocol version %q, expect %q", s.path, resp.ProtocolVersion, PluginProtocolVersion)
+	}
+
+	switch resp.Status {
+	case PluginStatusOK:
+	case PluginStatusFailed:
+		if len(resp.Error) == 0 {
+			return fmt.Errorf("plugin %s reported failure", s.path)
+		}
+		return fmt.Errorf("plugin %s failed: %s", s.path, resp.Error)
+	default:
+		return fmt.Errorf("plugin %s returned unknown status %q", s.path, resp.Status)
+	}
+	for name, value := range resp.Outputs {
+		in.SetOutput(name, value)
+	}
+	return nil
+}
+
+func pluginLogLevel(level string) LogLevel {
+	switch level {
+	case "error":
+		return ErrorLevel
+	case "warn":
+		return WarnLevel
+	default:
+		return InfoLevel
+	}
+}
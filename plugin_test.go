@@ -0,0 +1,129 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPlugin = `#!/bin/sh
+req=$(cat)
+echo "plugin started" >&2
+case "$req" in
+*'"protocolVersion":"v1"'*'"greeting":"hello aide"'*) ;;
+*) echo '{"protocolVersion":"v1","status":"failed","error":"unexpected request"}'; exit 0 ;;
+esac
+echo '{"protocolVersion":"v1","status":"ok","outputs":{"reply":"hi"},"messages":[{"level":"warn","message":"careful"}]}'
+`
+
+func TestPipeline_plugin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, PluginPrefix+"greet"), []byte(testPlugin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, PluginPrefix+"broken"), []byte("#!/bin/sh\necho oops\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The plugin replies with its environment.
+	env := `#!/bin/sh
+echo '{"protocolVersion":"v1","status":"ok","outputs":{"host":"'"${AIDE_PLUGIN_HOST:-}"'","who":"'"${who:-}"'"}}'
+`
+	if err := os.WriteFile(filepath.Join(dir, PluginPrefix+"env"), []byte(env), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AIDE_PLUGIN_HOST", "host")
+
+	tests := []struct {
+		name    string
+		uses    string
+		with    map[string]interface{}
+		inherit *EnvInherit
+		check   string
+		wantErr string
+		wantLog []string
+	}{
+		{
+			name:    "ok",
+			uses:    "greet",
+			with:    map[string]interface{}{"greeting": "hello {{ .env.who }}"},
+			wantLog: []string{`"level":"info"`, `"msg":"plugin started"`, `"level":"warn"`, `"msg":"careful"`},
+		},
+		{
+			name:    "failed",
+			uses:    "greet",
+			with:    map[string]interface{}{"greeting": "bye"},
+			wantErr: "plugin " + filepath.Join(dir, PluginPrefix+"greet") + " failed: unexpected request",
+		},
+		{
+			name:  "env",
+			uses:  "env",
+			check: `test "$call_host" = host && test "$call_who" = aide`,
+		},
+		{
+			name:    "env inherits none",
+			uses:    "env",
+			inherit: &EnvInherit{Mode: InheritNone},
+			check:   `test -z "$call_host" && test "$call_who" = aide`,
+		},
+		{
+			name:    "invalid response",
+			uses:    "broken",
+			wantErr: "invalid response",
+		},
+		{
+			name:    "not found",
+			uses:    "missing",
+			wantErr: `unknown step type "missing"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			p := NewPipeline("plugin")
+			p.Metadata.Labels = map[string]string{"who": "aide"}
+			p.Spec.Inherit = tt.inherit
+			p.AddUsesStep("call", tt.uses, tt.with)
+			check := tt.check
+			if len(check) == 0 {
+				check = `test "$call_reply" = hi`
+			}
+			p.AddStep("check", nil, check)
+			p.SetPluginDir(dir)
+			p.SetStateDir("")
+			p.SetLogger(NewJSONLog(&logs))
+
+			err := p.Execute(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			for _, want := range tt.wantLog {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("logs %s do not contain %s", logs.String(), want)
+				}
+			}
+		})
+	}
+}
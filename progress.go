@@ -0,0 +1,288 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressTailLines = 5
+	progressInterval  = 100 * time.Millisecond
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// isTerminal reports whether w is an interactive terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// progress renders the running steps with spinners, the elapsed time and the tail
+// of their output on an interactive terminal, the finished steps are collapsed to a single line.
+// The steps with forEach can run concurrently, so each running step is tracked by its number.
+type progress struct {
+	mu  sync.Mutex
+	out io.Writer
+	// width is the width of the terminal, the longer lines are truncated to keep the layout.
+	width int
+
+	// steps are the running steps in the order they are started.
+	steps []*progressStep
+	drawn int
+	frame int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+type progressStep struct {
+	name  string
+	num   int
+	total int
+	start time.Time
+	// tail is the last lines of the output of the step.
+	tail []string
+}
+
+func (s *progressStep) String() string {
+	return fmt.Sprintf("[%d/%d] %s", s.num, s.total, s.name)
+}
+
+// newProgress returns the progress rendered to out, it returns nil if out is not a terminal.
+func newProgress(out io.Writer) *progress {
+	if !isTerminal(out) {
+		return nil
+	}
+	p := &progress{out: out}
+	if width, _, err := term.GetSize(int(out.(*os.File).Fd())); err == nil {
+		p.width = width
+	}
+	return p
+}
+
+var _ Observer = (*progress)(nil)
+
+// Observe renders the progress by the step events.
+func (p *progress) Observe(e Event) {
+	switch e.Type {
+	case EventStepStarted:
+		p.startStep(e.Step, e.Num, e.Total)
+	case EventStepFinished:
+		p.finishStep(e.Num, e.Err)
+	case EventStepSkipped:
+		p.skipStep(e.Step, e.Num, e.Total)
+	}
+}
+
+// startStep starts rendering the step until it is finished.
+func (p *progress) startStep(name string, num, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.steps = append(p.steps, &progressStep{name: name, num: num, total: total, start: time.Now()})
+	p.draw()
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.spin(p.stop, p.done)
+	}
+}
+
+func (p *progress) spin(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.clear()
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// finishStep collapses the running step to a line marked by the result.
+func (p *progress) finishStep(num int, err error) {
+	if p == nil {
+		return
+	}
+	mark := "✓"
+	if err != nil {
+		mark = "✗"
+	}
+
+	p.mu.Lock()
+	index := -1
+	for i, step := range p.steps {
+		if step.num == num {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		p.mu.Unlock()
+		return
+	}
+	step := p.steps[index]
+	p.steps = append(p.steps[:index], p.steps[index+1:]...)
+
+	p.clear()
+	line := fmt.Sprintf("%s %s (%s)", mark, step, elapsed(step.start))
+	fmt.Fprintln(p.out, p.truncate(line))
+	// Keep the output of the failed step for troubleshooting.
+	if err != nil {
+		for _, v := range step.tail {
+			fmt.Fprintln(p.out, p.truncate("    "+v))
+		}
+	}
+	p.draw()
+
+	// Stop the spinner when no step is running.
+	stop, done := p.stop, p.done
+	if len(p.steps) > 0 || stop == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+	close(stop)
+	<-done
+}
+
+// skipStep prints the line of the skipped step.
+func (p *progress) skipStep(name string, num, total int) {
+	if p == nil {
+		return
+	}
+	step := &progressStep{name: name, num: num, total: total}
+	p.println(fmt.Sprintf("↷ %s (skipped)", step))
+}
+
+// println prints line above the running steps.
+func (p *progress) println(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	fmt.Fprintln(p.out, line)
+	p.draw()
+}
+
+// appendTail appends the line of the output of the running step num,
+// or of the step started last if num is 0.
+func (p *progress) appendTail(num int, line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var step *progressStep
+	for _, v := range p.steps {
+		if num == 0 || v.num == num {
+			step = v
+		}
+	}
+	if step == nil {
+		return
+	}
+	step.tail = append(step.tail, strings.TrimRight(line, "\r"))
+	if len(step.tail) > progressTailLines {
+		step.tail = step.tail[len(step.tail)-progressTailLines:]
+	}
+	p.clear()
+	p.draw()
+}
+
+// tailWriter returns the writer of the output of the running step num,
+// or of the step started last if num is 0.
+func (p *progress) tailWriter(num int) *lineWriter {
+	return &lineWriter{log: func(line string) { p.appendTail(num, line) }}
+}
+
+// draw renders the running steps and their output tails, the caller must hold the lock.
+func (p *progress) draw() {
+	frame := spinnerFrames[p.frame%len(spinnerFrames)]
+	var lines []string
+	for _, step := range p.steps {
+		lines = append(lines, fmt.Sprintf("%s %s (%s)", frame, step, elapsed(step.start)))
+		for _, v := range step.tail {
+			lines = append(lines, "    "+v)
+		}
+	}
+	for _, line := range lines {
+		fmt.Fprintln(p.out, p.truncate(line))
+	}
+	p.drawn = len(lines)
+}
+
+// clear erases the lines drawn, the caller must hold the lock.
+func (p *progress) clear() {
+	if p.drawn == 0 {
+		return
+	}
+	fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.drawn)
+	p.drawn = 0
+}
+
+// truncate cuts line to the width of the terminal so that it does not wrap.
+func (p *progress) truncate(line string) string {
+	if p.width <= 0 {
+		return line
+	}
+	rs := []rune(line)
+	if len(rs) < p.width {
+		return line
+	}
+	return string(rs[:p.width-1])
+}
+
+func elapsed(start time.Time) string {
+	return fmt.Sprintf("%.1fs", time.Since(start).Seconds())
+}
+
+var _ LogInterface = (*progressLog)(nil)
+
+// progressLog prints the logs above the running steps, and the output written to its Writer
+// is shown as the tail of the running step num, or of the step started last if num is 0.
+type progressLog struct {
+	*defaultLog
+	tail io.Writer
+}
+
+func newProgressLog(p *progress, num int) LogInterface {
+	w := &lineWriter{log: p.println}
+	return &progressLog{
+		defaultLog: &defaultLog{entry: log.New(w, "", 0)},
+		tail:       p.tailWriter(num),
+	}
+}
+
+func (l *progressLog) Writer() io.Writer {
+	return l.tail
+}
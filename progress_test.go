@@ -0,0 +1,85 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{out: &buf}
+
+	p.startStep("build", 1, 3)
+	_, _ = p.tailWriter(1).Write([]byte("compiling\n"))
+	p.finishStep(1, nil)
+	p.skipStep("test", 2, 3)
+	p.startStep("deploy", 3, 3)
+	_, _ = p.tailWriter(0).Write([]byte("denied\n"))
+	p.finishStep(3, errors.New("failed"))
+
+	want := []string{"✓ [1/3] build", "↷ [2/3] test", "✗ [3/3] deploy", "    denied"}
+	if got := collapsedLines(buf.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(buf.String(), "    compiling") {
+		t.Error("the output should be shown as the tail of the running step")
+	}
+}
+
+func TestProgress_concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{out: &buf}
+
+	p.startStep("install[api]", 1, 2)
+	p.startStep("install[web]", 2, 2)
+	_, _ = p.tailWriter(1).Write([]byte("api failed\n"))
+	_, _ = p.tailWriter(2).Write([]byte("web done\n"))
+	p.finishStep(2, nil)
+	p.finishStep(1, errors.New("failed"))
+
+	want := []string{"✓ [2/2] install[web]", "✗ [1/2] install[api]", "    api failed"}
+	if got := collapsedLines(buf.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if p.stop != nil {
+		t.Error("the spinner should be stopped when no step is running")
+	}
+}
+
+// collapsedLines returns the lines of the finished and skipped steps,
+// with the output tails of the failed steps.
+func collapsedLines(out string) []string {
+	var lines []string
+	failed := false
+	for _, line := range strings.Split(out, "\n") {
+		if i := strings.LastIndex(line, "\x1b[J"); i >= 0 {
+			line = line[i+len("\x1b[J"):]
+		}
+		switch {
+		case strings.HasPrefix(line, "✓") || strings.HasPrefix(line, "✗") || strings.HasPrefix(line, "↷"):
+			failed = strings.HasPrefix(line, "✗")
+			lines = append(lines, strings.Split(line, " (")[0])
+		case failed && strings.HasPrefix(line, "    "):
+			lines = append(lines, line)
+		default:
+			failed = false
+		}
+	}
+	return lines
+}
@@ -0,0 +1,241 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionRegexp matches the semantic versions, with an optional v prefix.
+var VersionRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// JSONSchema implements jsonSchemaer, the empty value which disables the check is allowed,
+// it is written when the pipeline is marshaled by aide build.
+func (PathCheck) JSONSchema() *JSONSchema {
+	return &JSONSchema{Type: "string", Enum: append(pathCheckNames(), "")}
+}
+
+func pathCheckNames() []string {
+	names := make([]string, 0, len(pathChecks))
+	for _, v := range pathChecks {
+		names = append(names, string(v))
+	}
+	return names
+}
+
+// normalize checks the answer of the prompt and returns its canonical form,
+// e.g. the numbers are formatted without redundant zeros. The empty answer is not checked.
+func (v *SpecPrompt) normalize(answer string) (string, error) {
+	if len(answer) == 0 {
+		return answer, nil
+	}
+	switch v.Type {
+	case PromptNumber:
+		return v.normalizeNumber(answer)
+	case PromptPath:
+		if err := checkPath(answer, v.PathCheck); err != nil {
+			return "", err
+		}
+	case PromptVersion:
+		if !VersionRegexp.MatchString(answer) {
+			return "", fmt.Errorf("%q is not a semantic version, e.g. 1.2.3", answer)
+		}
+	case PromptSelect:
+		if !containsString(v.Enum, answer) {
+			return "", fmt.Errorf("%q is not one of %s", answer, strings.Join(v.Enum, ", "))
+		}
+	case PromptMultiSelect:
+		for _, item := range strings.Split(answer, ",") {
+			if !containsString(v.Enum, item) {
+				return "", fmt.Errorf("%q is not one of %s", item, strings.Join(v.Enum, ", "))
+			}
+		}
+	}
+	return answer, nil
+}
+
+// loadEnum loads the options from the source, the earlier answers are available
+// to the command as environment variables and to the file path as templates.
+func (p *Pipeline) loadEnum(ctx context.Context, from *SpecEnumFrom, envSet map[string]string) ([]string, error) {
+	var data []byte
+	if len(from.Command) > 0 {
+		env, err := p.stepEnv(envSet, &SpecStep{})
+		if err != nil {
+			return nil, err
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", from.Command)
+		cmd.Env = envToSlice(env)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("run command (%s) failed: %v: %s", from.Command, err, strings.TrimSpace(stderr.String()))
+		}
+		data = out
+	} else {
+		name, err := renderText(envSet, "file", from.File)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	separator := from.Separator
+	if len(separator) == 0 {
+		separator = "\n"
+	}
+	var options []string
+	for _, item := range strings.Split(string(data), separator) {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			options = append(options, item)
+		}
+	}
+	return options, nil
+}
+
+func (v *SpecPrompt) normalizeNumber(answer string) (string, error) {
+	var (
+		number    float64
+		canonical string
+	)
+	answer = strings.TrimSpace(answer)
+	if v.Integer {
+		i, err := strconv.ParseInt(answer, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", answer)
+		}
+		number, canonical = float64(i), strconv.FormatInt(i, 10)
+	} else {
+		f, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", answer)
+		}
+		number, canonical = f, strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if v.Min != nil && number < *v.Min {
+		return "", fmt.Errorf("%s is less than the minimum %s", canonical, formatNumber(*v.Min))
+	}
+	if v.Max != nil && number > *v.Max {
+		return "", fmt.Errorf("%s is greater than the maximum %s", canonical, formatNumber(*v.Max))
+	}
+	return canonical, nil
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// checkPath checks the path by check.
+func checkPath(path string, check PathCheck) error {
+	_, err := os.Stat(path)
+	switch check {
+	case PathMustExist:
+		if err != nil {
+			return fmt.Errorf("path %s does not exist", path)
+		}
+	case PathMustNotExist:
+		if err == nil {
+			return fmt.Errorf("path %s already exists", path)
+		}
+	case PathCreatable:
+		if err == nil {
+			return nil
+		}
+		// The nearest existing parent must be a directory.
+		for dir := filepath.Dir(filepath.Clean(path)); ; dir = filepath.Dir(dir) {
+			stat, err := os.Stat(dir)
+			if err == nil {
+				if !stat.IsDir() {
+					return fmt.Errorf("path %s cannot be created, %s is not a directory", path, dir)
+				}
+				return nil
+			}
+			if !errors.Is(err, os.ErrNotExist) || dir == filepath.Dir(dir) {
+				return fmt.Errorf("path %s cannot be created: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// suggestPaths returns the paths which start with toComplete, the directories end with a separator.
+func suggestPaths(toComplete string) []string {
+	matches, _ := filepath.Glob(toComplete + "*")
+	for k, match := range matches {
+		if stat, err := os.Stat(match); err == nil && stat.IsDir() {
+			matches[k] = match + string(filepath.Separator)
+		}
+	}
+	return matches
+}
+
+// flagUsage returns the usage of the flag bound to the prompt, with the localized help
+// and the hints of the accepted values.
+func (v *SpecPrompt) flagUsage(locale, fallback string) string {
+	help := v.Help.Localize(locale, fallback)
+	var hint string
+	switch v.Type {
+	case PromptNumber:
+		hint = "number"
+		if v.Integer {
+			hint = "integer"
+		}
+		switch {
+		case v.Min != nil && v.Max != nil:
+			hint += fmt.Sprintf(" between %s and %s", formatNumber(*v.Min), formatNumber(*v.Max))
+		case v.Min != nil:
+			hint += " >= " + formatNumber(*v.Min)
+		case v.Max != nil:
+			hint += " <= " + formatNumber(*v.Max)
+		}
+	case PromptPath:
+		hint = "path"
+		if len(v.PathCheck) > 0 {
+			hint += ", " + string(v.PathCheck)
+		}
+	case PromptVersion:
+		hint = "semantic version"
+	case PromptConfirm:
+		hint = "true or false"
+	case PromptSelect:
+		if v.EnumFrom == nil && !isTemplate(v.Enum...) {
+			hint = "one of " + strings.Join(v.Enum, ", ")
+		}
+	case PromptMultiSelect:
+		if v.EnumFrom == nil && !isTemplate(v.Enum...) {
+			hint = "comma separated of " + strings.Join(v.Enum, ", ")
+		}
+	}
+	if len(hint) == 0 {
+		return help
+	}
+	if len(help) == 0 {
+		return hint
+	}
+	return fmt.Sprintf("%s (%s)", help, hint)
+}
@@ -0,0 +1,206 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_templatedPrompts(t *testing.T) {
+	p := NewPipeline("test")
+	p.AddInputPrompt("app_name", "Application name?", "demo", "")
+	p.AddSelectPrompt("edition", "Which edition of {{ .env.app_name }}?",
+		[]string{"community", "{{ if eq .env.app_name \"demo\" }}enterprise{{ end }}"}, "", "")
+	p.AddInputPrompt("data_dir", "Data dir of the {{ .env.edition }} edition?", "/opt/{{ .env.app_name }}/data", "")
+	p.AddInputPrompt("early", "{{ .env.late }}", "", "")
+	p.AddInputPrompt("late", "", "", "")
+	p.AddStep("", nil, "echo $data_dir $early")
+	p.skipPrompt = true
+
+	envSet := map[string]string{}
+	if err := p.executePrompts(context.Background(), envSet); err != nil {
+		t.Fatal(err)
+	}
+	if envSet["data_dir"] != "/opt/demo/data" || envSet["edition"] != "community" {
+		t.Errorf("answers = %v", envSet)
+	}
+
+	v, err := p.Spec.Prompts[1].resolve(envSet, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Message.Text != "Which edition of demo?" || !reflect.DeepEqual(v.Enum, []string{"community", "enterprise"}) {
+		t.Errorf("resolve() = %+v", v)
+	}
+	envSet["app_name"] = "other"
+	if v, _ = p.Spec.Prompts[1].resolve(envSet, "", ""); !reflect.DeepEqual(v.Enum, []string{"community"}) {
+		t.Errorf("resolve() enum = %v, the empty items should be dropped", v.Enum)
+	}
+
+	var got []string
+	for _, e := range p.Lint() {
+		got = append(got, e.Error())
+	}
+	want := []string{`spec.prompts[3].message: warning: undefined variable "late"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSpecPrompt_normalize(t *testing.T) {
+	one, ten := 1.0, 10.0
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		prompt  SpecPrompt
+		answer  string
+		want    string
+		wantErr bool
+	}{
+		{name: "float", prompt: SpecPrompt{Type: PromptNumber}, answer: "01.50", want: "1.5"},
+		{name: "integer", prompt: SpecPrompt{Type: PromptNumber, Integer: true}, answer: " 007 ", want: "7"},
+		{name: "not integer", prompt: SpecPrompt{Type: PromptNumber, Integer: true}, answer: "1.5", wantErr: true},
+		{name: "not number", prompt: SpecPrompt{Type: PromptNumber}, answer: "abc", wantErr: true},
+		{name: "in range", prompt: SpecPrompt{Type: PromptNumber, Min: &one, Max: &ten}, answer: "10", want: "10"},
+		{name: "below min", prompt: SpecPrompt{Type: PromptNumber, Min: &one}, answer: "0.5", wantErr: true},
+		{name: "above max", prompt: SpecPrompt{Type: PromptNumber, Max: &ten}, answer: "11", wantErr: true},
+		{name: "empty", prompt: SpecPrompt{Type: PromptNumber}, answer: "", want: ""},
+		{name: "version", prompt: SpecPrompt{Type: PromptVersion}, answer: "v1.2.3-rc.1+build.5", want: "v1.2.3-rc.1+build.5"},
+		{name: "invalid version", prompt: SpecPrompt{Type: PromptVersion}, answer: "1.2", wantErr: true},
+		{name: "must exist", prompt: SpecPrompt{Type: PromptPath, PathCheck: PathMustExist}, answer: file, want: file},
+		{name: "not exist", prompt: SpecPrompt{Type: PromptPath, PathCheck: PathMustExist}, answer: file + "x", wantErr: true},
+		{name: "must not exist", prompt: SpecPrompt{Type: PromptPath, PathCheck: PathMustNotExist}, answer: file, wantErr: true},
+		{name: "creatable", prompt: SpecPrompt{Type: PromptPath, PathCheck: PathCreatable}, answer: filepath.Join(dir, "a", "b"), want: filepath.Join(dir, "a", "b")},
+		{name: "not creatable", prompt: SpecPrompt{Type: PromptPath, PathCheck: PathCreatable}, answer: filepath.Join(file, "a"), wantErr: true},
+		{name: "option", prompt: SpecPrompt{Type: PromptSelect, Enum: []string{"a", "b"}}, answer: "b", want: "b"},
+		{name: "unknown option", prompt: SpecPrompt{Type: PromptSelect, Enum: []string{"a", "b"}}, answer: "c", wantErr: true},
+		{name: "options", prompt: SpecPrompt{Type: PromptMultiSelect, Enum: []string{"a", "b"}}, answer: "a,b", want: "a,b"},
+		{name: "unknown options", prompt: SpecPrompt{Type: PromptMultiSelect, Enum: []string{"a", "b"}}, answer: "a,c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.prompt.normalize(tt.answer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipeline_numberFlag(t *testing.T) {
+	one, ten := 1.0, 10.0
+	p := NewPipeline("test")
+	p.AddIntegerPrompt("replicas", "Replicas?", "3", &one, &ten, "The number of replicas")
+	p.AddStep("", nil, "echo $replicas")
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	p.BindFlags(set)
+	if usage := set.Lookup("replicas").Usage; usage != "The number of replicas (integer between 1 and 10)" {
+		t.Errorf("usage = %q", usage)
+	}
+	if err := set.Parse([]string{"--skip-prompt", "--replicas", "11"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.executePrompts(context.Background(), map[string]string{}); err == nil {
+		t.Error("executePrompts() should reject the value out of range")
+	}
+
+	p.Spec.Prompts[0].Default = "x"
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject the invalid default")
+	}
+}
+
+func TestPipeline_enumFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "regions")
+	if err := os.WriteFile(file, []byte("us-east\n\n eu-west \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPipeline("test")
+	p.AddInputPrompt("app_name", "Application name?", "demo", "")
+	p.AddSelectPrompt("cluster", "Cluster?", nil, "", "")
+	p.Spec.Prompts[1].EnumFrom = &SpecEnumFrom{Command: `printf '%s-a,%s-b' "$app_name" "$app_name"`, Separator: ","}
+	p.AddMultiSelectPrompt("regions", "Regions?", nil, nil, "")
+	p.Spec.Prompts[2].EnumFrom = &SpecEnumFrom{File: file}
+	p.AddStep("", nil, "echo $cluster $regions")
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if errs := p.Lint(); len(errs) > 0 {
+		t.Errorf("Lint() = %v", errs)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	p.BindFlags(set)
+	if err := set.Parse([]string{"--skip-prompt", "--regions", "eu-west"}); err != nil {
+		t.Fatal(err)
+	}
+	envSet := map[string]string{}
+	if err := p.executePrompts(context.Background(), envSet); err != nil {
+		t.Fatal(err)
+	}
+	if envSet["cluster"] != "demo-a" || envSet["regions"] != "eu-west" {
+		t.Errorf("answers = %v", envSet)
+	}
+
+	if err := set.Set("cluster", "demo-c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.executePrompts(context.Background(), map[string]string{}); err == nil {
+		t.Error("executePrompts() should reject the value which is not an option")
+	}
+
+	p.Spec.Prompts[1].EnumFrom.File = file
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject both command and file")
+	}
+}
+
+func TestPipeline_closedStdin(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	origin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = origin }()
+
+	p := NewPipeline("test")
+	p.AddInputPrompt("name", "Name", "aide", "")
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	err = p.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ask prompt name failed: stdin is closed") {
+		t.Errorf("Execute() error = %v, want the closed stdin error", err)
+	}
+}
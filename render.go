@@ -0,0 +1,270 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/99nil/gopkg/sets"
+)
+
+// Render resolves the answers the same way as Execute, and runs only the render steps.
+// If names are specified, only the render steps with the names are run.
+// If dir is not empty, the files are written under dir instead of their dest.
+func (p *Pipeline) Render(ctx context.Context, dir string, names ...string) error {
+	selected := sets.NewString(names...)
+	for _, name := range names {
+		found := false
+		for _, step := range p.Spec.Steps {
+			if step.Render != nil && step.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("render step %s is not defined", name)
+		}
+	}
+
+	envSet, err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	// The steps with forEach are rendered once per item like Execute.
+	runs, err := p.stepRuns(envSet)
+	if err != nil {
+		return err
+	}
+	for k, step := range p.Spec.Steps {
+		if step.Render == nil {
+			continue
+		}
+		for _, run := range runs[k] {
+			runEnv := envSet
+			if step.ForEach != nil {
+				runEnv = itemEnv(envSet, run.item)
+			}
+			if selected.Len() > 0 && !selected.Has(step.Name) {
+				// Register the outputs as if the step is executed.
+				err = p.registerOutputs(runEnv, &p.Spec.Steps[k])
+			} else {
+				err = p.renderStep(runEnv, k, dir)
+			}
+			if err != nil {
+				return p.redactError(err)
+			}
+			copyOutputs(envSet, runEnv, &p.Spec.Steps[k])
+		}
+	}
+	return nil
+}
+
+// registerOutputs registers the outputs of the step into the variable set,
+// the dest is registered as the rendered path.
+func (p *Pipeline) registerOutputs(envSet map[string]string, step *SpecStep) error {
+	if step.Render == nil {
+		return nil
+	}
+	dest, err := renderText(envSet, "dest", step.Render.Dest)
+	if err != nil {
+		return fmt.Errorf("render dest failed: %v", err)
+	}
+	envSet[step.Name+"_src"] = step.Render.Src
+	envSet[step.Name+"_dest"] = dest
+	return nil
+}
+
+// renderStep registers the outputs of the render step and renders it,
+// the dest is placed under dir if dir is not empty.
+func (p *Pipeline) renderStep(envSet map[string]string, index int, dir string) error {
+	step := &p.Spec.Steps[index]
+	if err := p.registerOutputs(envSet, step); err != nil {
+		return fmt.Errorf("render[%d] failed: %v", index, err)
+	}
+
+	r := step.Render
+	if len(dir) > 0 {
+		scratch := *r
+		scratch.Dest = filepath.Join(dir, r.Dest)
+		r = &scratch
+	}
+	if err := p.render(envSet, r); err != nil {
+		return fmt.Errorf("render[%d] failed: %v", index, err)
+	}
+	return nil
+}
+
+// render renders the src of the render step to its dest,
+// src can be a single file or a directory. The paths are rendered as plain text.
+func (p *Pipeline) render(envSet map[string]string, r *SpecStepRender) error {
+	dest, err := renderText(envSet, "dest", r.Dest)
+	if err != nil {
+		return fmt.Errorf("render dest failed: %v", err)
+	}
+
+	var stat fs.FileInfo
+	if r.fsys != nil {
+		stat, err = fs.Stat(r.fsys, r.Src)
+	} else {
+		stat, err = os.Stat(r.Src)
+	}
+	if err != nil {
+		return fmt.Errorf("stat src failed: %v", err)
+	}
+	if stat.IsDir() {
+		return p.renderDir(envSet, r, dest)
+	}
+	if matchGlobs(r.Raw, path.Base(filepath.ToSlash(r.Src))) {
+		return copyFile(r.fsys, r.Src, dest)
+	}
+	return p.renderFile(envSet, r.fsys, r.Src, dest)
+}
+
+// renderDir walks the entries of the src directory and renders them into dest.
+// The names of the entries can contain templates, and the globs in Include, Exclude
+// and Raw are matched against the slash-separated path relative to src.
+func (p *Pipeline) renderDir(envSet map[string]string, r *SpecStepRender, dest string) error {
+	fsys, err := r.dirFS()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, fs.ModePerm); err != nil {
+		return err
+	}
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		if matchGlobs(r.Exclude, name) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		target, err := renderText(envSet, name, name)
+		if err != nil {
+			return fmt.Errorf("render path(%s) failed: %v", name, err)
+		}
+		target = filepath.Join(dest, filepath.FromSlash(target))
+
+		if d.IsDir() {
+			// Directories are created on demand when include is specified,
+			// so that no empty directories are left behind.
+			if len(r.Include) > 0 {
+				return nil
+			}
+			return os.MkdirAll(target, fs.ModePerm)
+		}
+		if len(r.Include) > 0 && !matchGlobs(r.Include, name) {
+			return nil
+		}
+		if matchGlobs(r.Raw, name) {
+			return copyFile(fsys, name, target)
+		}
+		return p.renderFile(envSet, fsys, name, target)
+	})
+}
+
+// dirFS returns the fs.FS rooted at the src directory.
+func (r *SpecStepRender) dirFS() (fs.FS, error) {
+	if r.fsys != nil {
+		return fs.Sub(r.fsys, path.Clean(filepath.ToSlash(r.Src)))
+	}
+	return os.DirFS(r.Src), nil
+}
+
+func (p *Pipeline) renderFile(envSet map[string]string, fsys fs.FS, src, dest string) error {
+	if err := ensureDir(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if fsys != nil {
+		b, err = fs.ReadFile(fsys, src)
+	} else {
+		b, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return err
+	}
+
+	t, err := parseTemplate(src, string(b))
+	if err != nil {
+		return fmt.Errorf("parse template(%s) failed: %v", src, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, templateData(envSet)); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, buf.Bytes(), fs.ModePerm)
+}
+
+// copyFile copies src to dest byte-for-byte.
+func copyFile(fsys fs.FS, src, dest string) error {
+	if err := ensureDir(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	var (
+		in  io.ReadCloser
+		err error
+	)
+	if fsys != nil {
+		in, err = fsys.Open(src)
+	} else {
+		in, err = os.Open(src)
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.ModePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func ensureDir(dir string) error {
+	_, err := os.Stat(dir)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return os.MkdirAll(dir, fs.ModePerm)
+}
@@ -0,0 +1,197 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPipeline_render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"res/{{.env.app}}.conf":   {Data: []byte("name={{.env.app}}")},
+		"res/bin/logo.png":        {Data: []byte("{{raw}}")},
+		"res/docs/readme.md":      {Data: []byte("readme")},
+		"res/conf/{{.env.app}}/a": {Data: []byte("a={{.env.app}}")},
+		"single.in":               {Data: []byte("single {{.env.app}}")},
+	}
+	envSet := map[string]string{"app": "demo", "owner": "O'Brien & <Co>"}
+
+	type args struct {
+		render *SpecStepRender
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+		miss []string
+	}{
+		{
+			name: "case 1: single file",
+			args: args{
+				render: NewEmbedStepRender(fsys, "single.in", "{{.env.app}}.out"),
+			},
+			want: map[string]string{"demo.out": "single demo"},
+		},
+		{
+			name: "case 2: paths are not escaped",
+			args: args{
+				render: NewEmbedStepRender(fsys, "single.in", "{{.env.owner}}.out"),
+			},
+			want: map[string]string{"O'Brien & <Co>.out": "single demo"},
+		},
+		{
+			name: "case 3: directory with templated paths and raw",
+			args: args{
+				render: NewEmbedStepRender(fsys, "res", "out").WithRaw("*.png"),
+			},
+			want: map[string]string{
+				"out/demo.conf":      "name=demo",
+				"out/bin/logo.png":   "{{raw}}",
+				"out/docs/readme.md": "readme",
+				"out/conf/demo/a":    "a=demo",
+			},
+		},
+		{
+			name: "case 4: include and exclude",
+			args: args{
+				render: NewEmbedStepRender(fsys, "res", "out").
+					WithInclude("*.conf", "*.md").
+					WithExclude("docs"),
+			},
+			want: map[string]string{"out/demo.conf": "name=demo"},
+			miss: []string{"out/docs", "out/bin", "out/conf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.args.render.Dest = filepath.Join(dir, tt.args.render.Dest)
+
+			p := NewPipeline("test")
+			if err := p.render(envSet, tt.args.render); err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			for name, want := range tt.want {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("read %s error = %v", name, err)
+				}
+				if string(b) != want {
+					t.Errorf("render() %s = %q, want %q", name, string(b), want)
+				}
+			}
+			for _, name := range tt.miss {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("render() %s should not exist", name)
+				}
+			}
+		})
+	}
+}
+
+func TestPipeline_renderOutputs(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPipeline("test")
+	p.AddInputPrompt("app", "App?", "demo", "")
+	p.AddStep("config", NewEmbedStepRender(fstest.MapFS{"a.in": {Data: []byte("a")}}, "a.in", dir+"/{{ .env.app }}.conf"), "")
+	p.skipPrompt = true
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Outputs()["config_dest"], dir+"/demo.conf"; got != want {
+		t.Errorf("config_dest = %q, want %q", got, want)
+	}
+}
+
+func TestPipeline_Render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.in":  {Data: []byte("app={{ .env.app }}")},
+		"back.in": {Data: []byte("from={{ .env.config_dest }}")},
+	}
+	newPipeline := func() *Pipeline {
+		p := NewPipeline("test")
+		p.AddInputPrompt("app", "App?", "demo", "")
+		p.AddStep("config", NewEmbedStepRender(fsys, "app.in", "/etc/{{ .env.app }}.conf"), "")
+		p.AddStep("fail", nil, "exit 1")
+		p.AddStep("backup", NewEmbedStepRender(fsys, "back.in", "{{ .env.config_dest }}.bak"), "")
+		p.skipPrompt = true
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		steps   []string
+		want    map[string]string
+		miss    []string
+		wantErr string
+	}{
+		{
+			name: "case 1: all render steps",
+			want: map[string]string{
+				"etc/demo.conf":     "app=demo",
+				"etc/demo.conf.bak": "from=/etc/demo.conf",
+			},
+		},
+		{
+			name:  "case 2: selected step with the outputs of the skipped steps",
+			steps: []string{"backup"},
+			want:  map[string]string{"etc/demo.conf.bak": "from=/etc/demo.conf"},
+			miss:  []string{"etc/demo.conf"},
+		},
+		{
+			name:    "case 3: undefined step",
+			steps:   []string{"missing"},
+			wantErr: "render step missing is not defined",
+		},
+		{
+			name:    "case 4: not a render step",
+			steps:   []string{"fail"},
+			wantErr: "render step fail is not defined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := newPipeline().Render(context.Background(), dir, tt.steps...)
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Render() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for name, content := range tt.want {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != content {
+					t.Errorf("%s = %q, want %q", name, b, content)
+				}
+			}
+			for _, name := range tt.miss {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s should not be rendered, stat error = %v", name, err)
+				}
+			}
+		})
+	}
+}
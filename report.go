@@ -0,0 +1,398 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/zc2638/aide/stage"
+)
+
+type ReportStatus string
+
+const (
+	StatusOK      ReportStatus = "ok"
+	StatusSkipped ReportStatus = "skipped"
+	StatusFailed  ReportStatus = "failed"
+)
+
+// Report defines the result of a run.
+type Report struct {
+	Name      string        `json:"name"`
+	Status    ReportStatus  `json:"status"`
+	StartTime time.Time     `json:"startTime"`
+	EndTime   time.Time     `json:"endTime"`
+	Error     string        `json:"error,omitempty"`
+	ExitCode  int           `json:"exitCode"`
+	Stages    []StageReport `json:"stages"`
+}
+
+// StageReport defines the result of a stage.
+type StageReport struct {
+	Name      string       `json:"name"`
+	Status    ReportStatus `json:"status"`
+	StartTime *time.Time   `json:"startTime,omitempty"`
+	EndTime   *time.Time   `json:"endTime,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Steps     []StepReport `json:"steps"`
+}
+
+// StepReport defines the result of a step.
+type StepReport struct {
+	Name      string       `json:"name"`
+	Num       int          `json:"num"`
+	Status    ReportStatus `json:"status"`
+	StartTime *time.Time   `json:"startTime,omitempty"`
+	EndTime   *time.Time   `json:"endTime,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	// ExitCode is the exit code of the command executed by the step.
+	ExitCode int `json:"exitCode"`
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitDuration(start, end *time.Time) string {
+	if start == nil || end == nil {
+		return "0"
+	}
+	return fmt.Sprintf("%.3f", end.Sub(*start).Seconds())
+}
+
+// WriteJUnit writes the report as JUnit XML, each stage is a test suite and each step is a test case.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{
+		Name: r.Name,
+		Time: junitDuration(&r.StartTime, &r.EndTime),
+	}
+	for _, s := range r.Stages {
+		suite := junitTestSuite{
+			Name: s.Name,
+			Time: junitDuration(s.StartTime, s.EndTime),
+		}
+		if s.StartTime != nil {
+			suite.Timestamp = s.StartTime.Format(time.RFC3339)
+		}
+		for _, step := range s.Steps {
+			tc := junitTestCase{
+				Name:      step.Name,
+				ClassName: s.Name,
+				Time:      junitDuration(step.StartTime, step.EndTime),
+			}
+			switch step.Status {
+			case StatusFailed:
+				tc.Failure = &junitFailure{Message: step.Error, Text: step.Error}
+				suite.Failures++
+			case StatusSkipped:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// reporter records the results into the report during a run,
+// and notifies the observers of the lifecycle events.
+type reporter struct {
+	mu     sync.Mutex
+	report *Report
+	// redact is used to redact the error messages.
+	redact    func(string) string
+	observers []Observer
+}
+
+func newReporter(name string, observers ...Observer) *reporter {
+	return &reporter{
+		report:    &Report{Name: name, Status: StatusOK, StartTime: time.Now()},
+		observers: observers,
+	}
+}
+
+// addStage adds a stage which is skipped until it starts, and returns its index.
+func (r *reporter) addStage(name string, steps ...string) int {
+	sr := StageReport{Name: name, Status: StatusSkipped}
+	for k, step := range steps {
+		sr.Steps = append(sr.Steps, StepReport{Name: step, Num: k + 1, Status: StatusSkipped})
+	}
+	r.report.Stages = append(r.report.Stages, sr)
+	return len(r.report.Stages) - 1
+}
+
+// setSteps replaces the steps of the stage before it starts, e.g. the steps are expanded.
+func (r *reporter) setSteps(index int, steps ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sr := &r.report.Stages[index]
+	sr.Steps = nil
+	for k, step := range steps {
+		sr.Steps = append(sr.Steps, StepReport{Name: step, Num: k + 1, Status: StatusSkipped})
+	}
+}
+
+func (r *reporter) message(err error) string {
+	if r.redact != nil {
+		return r.redact(err.Error())
+	}
+	return err.Error()
+}
+
+// eventError returns the error of the finished events,
+// the errors which control the flow are regarded as succeeded.
+func (r *reporter) eventError(err error) error {
+	if err == nil || err == stage.ErrStageEnd || err == stage.ErrStageSkip {
+		return nil
+	}
+	if r.redact != nil {
+		return &redactedError{msg: r.redact(err.Error()), err: err}
+	}
+	return err
+}
+
+// emit notifies the observers of the events, it must be called without holding the lock.
+func (r *reporter) emit(events ...Event) {
+	for _, e := range events {
+		for _, o := range r.observers {
+			o.Observe(e)
+		}
+	}
+}
+
+func (r *reporter) stageEvent(typ EventType, index int) Event {
+	s := &r.report.Stages[index]
+	return Event{Type: typ, Time: time.Now(), Stage: s.Name, Total: len(s.Steps)}
+}
+
+func (r *reporter) stepEvent(typ EventType, stageIndex, num int) Event {
+	e := r.stageEvent(typ, stageIndex)
+	e.Step = r.report.Stages[stageIndex].Steps[num-1].Name
+	e.Num = num
+	return e
+}
+
+func (r *reporter) startStage(index int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	s := &r.report.Stages[index]
+	s.Status = StatusOK
+	s.StartTime = now()
+	e := r.stageEvent(EventStageStarted, index)
+	r.mu.Unlock()
+
+	r.emit(e)
+}
+
+// skipStage notifies the observers of the stage which is skipped before it starts.
+func (r *reporter) skipStage(index int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	e := r.stageEvent(EventStageSkipped, index)
+	r.mu.Unlock()
+
+	r.emit(e)
+}
+
+func (r *reporter) finishStage(index int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	s := &r.report.Stages[index]
+	s.EndTime = now()
+	switch {
+	case err == stage.ErrStageSkip:
+		s.Status = StatusSkipped
+	case err != nil && err != stage.ErrStageEnd:
+		s.Status = StatusFailed
+		s.Error = r.message(err)
+	}
+	e := r.stageEvent(EventStageFinished, index)
+	if err == stage.ErrStageSkip {
+		e.Type = EventStageSkipped
+	}
+	e.Err = r.eventError(err)
+	e.Duration = duration(s.StartTime, s.EndTime)
+	r.mu.Unlock()
+
+	r.emit(e)
+}
+
+func (r *reporter) startStep(stageIndex, num int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	s := &r.report.Stages[stageIndex].Steps[num-1]
+	s.Status = StatusOK
+	s.StartTime = now()
+	e := r.stepEvent(EventStepStarted, stageIndex, num)
+	r.mu.Unlock()
+
+	r.emit(e)
+}
+
+// skipStep marks the step as skipped, e.g. it has been completed in the last run.
+func (r *reporter) skipStep(stageIndex, num int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.report.Stages[stageIndex].Steps[num-1].Status = StatusSkipped
+	e := r.stepEvent(EventStepSkipped, stageIndex, num)
+	r.mu.Unlock()
+
+	r.emit(e)
+}
+
+func (r *reporter) finishStep(stageIndex, num int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	s := &r.report.Stages[stageIndex].Steps[num-1]
+	s.EndTime = now()
+	e := r.stepEvent(EventStepFinished, stageIndex, num)
+	e.Err = r.eventError(err)
+	e.Duration = duration(s.StartTime, s.EndTime)
+	events := []Event{e}
+	if e.Err != nil {
+		s.Status = StatusFailed
+		s.Error = r.message(err)
+		s.ExitCode = exitCode(err)
+
+		// The stage fails with its step.
+		st := &r.report.Stages[stageIndex]
+		st.Status = StatusFailed
+		st.EndTime = s.EndTime
+		st.Error = s.Error
+
+		se := r.stageEvent(EventStageFinished, stageIndex)
+		se.Err = e.Err
+		se.Duration = duration(st.StartTime, st.EndTime)
+		events = append(events, se)
+	}
+	r.mu.Unlock()
+
+	r.emit(events...)
+}
+
+// finish completes the report with the result of the run.
+func (r *reporter) finish(err error) *Report {
+	r.mu.Lock()
+	r.report.EndTime = time.Now()
+	if err != nil && err != stage.ErrStageEnd {
+		r.report.Status = StatusFailed
+		r.report.Error = r.message(err)
+		r.report.ExitCode = exitCode(err)
+	}
+	e := Event{
+		Type:     EventRunFinished,
+		Time:     r.report.EndTime,
+		Err:      r.eventError(err),
+		Duration: r.report.EndTime.Sub(r.report.StartTime),
+	}
+	r.mu.Unlock()
+
+	r.emit(e)
+	return r.report
+}
+
+func duration(start, end *time.Time) time.Duration {
+	if start == nil || end == nil {
+		return 0
+	}
+	return end.Sub(*start)
+}
+
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
+
+// exitCode returns the exit code of the command if err is caused by it, otherwise 1.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,280 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema defines the subset of JSON Schema used to describe the pipeline.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+// jsonSchemaer can be implemented by the types which cannot be described by reflection.
+type jsonSchemaer interface {
+	JSONSchema() *JSONSchema
+}
+
+// schemaRequired defines the required fields of the struct types.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(Pipeline{}):       {"apiVersion", "kind", "metadata", "spec"},
+	reflect.TypeOf(Metadata{}):       {"name"},
+	reflect.TypeOf(SpecPrompt{}):     {"name", "type"},
+	reflect.TypeOf(SpecVar{}):        {"name", "value"},
+	reflect.TypeOf(SpecStepRender{}): {"src", "dest"},
+}
+
+// PipelineSchema returns the JSON Schema of the Pipeline.
+func PipelineSchema() *JSONSchema {
+	s := schemaOf(reflect.TypeOf(Pipeline{}))
+	s.Schema = schemaDraft
+	s.Title = PipelineKind
+	return s
+}
+
+func schemaOf(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v, ok := reflect.New(t).Interface().(jsonSchemaer); ok {
+		return v.JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		s := &JSONSchema{
+			Type:                 "object",
+			Properties:           make(map[string]*JSONSchema),
+			AdditionalProperties: false,
+			Required:             schemaRequired[t],
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			s.Properties[name] = schemaOf(field.Type)
+		}
+		return s
+	default:
+		// Any value is allowed.
+		return &JSONSchema{}
+	}
+}
+
+// JSONSchema implements jsonSchemaer.
+func (PromptType) JSONSchema() *JSONSchema {
+	enum := make([]string, 0, len(promptTypes))
+	for _, v := range promptTypes {
+		enum = append(enum, string(v))
+	}
+	return &JSONSchema{Type: "string", Enum: enum}
+}
+
+// FieldError defines a problem located at a position of the pipeline configuration.
+type FieldError struct {
+	// Path is the path of the field, e.g. spec.steps[0].name.
+	Path    string
+	Line    int
+	Column  int
+	Message string
+	// Warning indicates that the problem is suspicious but does not prevent execution.
+	Warning bool
+}
+
+func (e *FieldError) Error() string {
+	message := e.Message
+	if e.Warning {
+		message = "warning: " + message
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, message)
+}
+
+// FieldErrors is a list of FieldError.
+type FieldErrors []*FieldError
+
+func (es FieldErrors) Error() string {
+	parts := make([]string, 0, len(es))
+	for _, e := range es {
+		parts = append(parts, e.Error())
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Errors returns the errors which are not warnings.
+func (es FieldErrors) Errors() FieldErrors {
+	var errs FieldErrors
+	for _, e := range es {
+		if !e.Warning {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// ValidateNode validates node against the schema,
+// path is the path of the node used in the errors.
+func (s *JSONSchema) ValidateNode(node *yaml.Node, path string) FieldErrors {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	newErr := func(n *yaml.Node, path, format string, args ...interface{}) *FieldError {
+		return &FieldError{Path: path, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)}
+	}
+	// Null is allowed for any value, it is decoded as the zero value.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	if len(s.OneOf) > 0 {
+		for _, sub := range s.OneOf {
+			if len(sub.ValidateNode(node, path)) == 0 {
+				return nil
+			}
+		}
+		return FieldErrors{newErr(node, path, "does not match any of the allowed formats")}
+	}
+
+	var errs FieldErrors
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return FieldErrors{newErr(node, path, "expect an object")}
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			seen[key.Value] = true
+			fieldPath := joinFieldPath(path, key.Value)
+
+			sub, ok := s.Properties[key.Value]
+			if !ok {
+				switch v := s.AdditionalProperties.(type) {
+				case *JSONSchema:
+					sub = v
+				case bool:
+					if !v {
+						errs = append(errs, newErr(key, fieldPath, "unknown field %q", key.Value))
+						continue
+					}
+				}
+			}
+			if sub != nil {
+				errs = append(errs, sub.ValidateNode(value, fieldPath)...)
+			}
+		}
+		for _, name := range s.Required {
+			if !seen[name] {
+				errs = append(errs, newErr(node, joinFieldPath(path, name), "required field is missing"))
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return FieldErrors{newErr(node, path, "expect an array")}
+		}
+		if s.Items != nil {
+			for i, item := range node.Content {
+				errs = append(errs, s.Items.ValidateNode(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string", "boolean", "integer", "number":
+		if node.Kind != yaml.ScalarNode {
+			return FieldErrors{newErr(node, path, "expect a %s", s.Type)}
+		}
+		switch {
+		case s.Type == "boolean" && node.Tag != "!!bool":
+			errs = append(errs, newErr(node, path, "expect a boolean, got %q", node.Value))
+		case s.Type == "integer" && node.Tag != "!!int":
+			errs = append(errs, newErr(node, path, "expect an integer, got %q", node.Value))
+		case s.Type == "number" && node.Tag != "!!int" && node.Tag != "!!float":
+			errs = append(errs, newErr(node, path, "expect a number, got %q", node.Value))
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, node.Value) {
+			errs = append(errs, newErr(node, path, "unknown value %q, expect one of %s", node.Value, strings.Join(s.Enum, ", ")))
+		}
+	}
+	return errs
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func containsString(set []string, s string) bool {
+	for _, v := range set {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFieldErrors sorts the errors by their positions.
+func sortFieldErrors(errs FieldErrors) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+}
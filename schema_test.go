@@ -0,0 +1,121 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "case 1: valid",
+			data: `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: test
+  labels:
+    project: aide
+spec:
+  prompts:
+    - name: gender
+      type: Select
+      enum: [ "male", "female" ]
+  steps:
+    - command: env
+`,
+		},
+		{
+			name: "case 2: unknown field",
+			data: `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: test
+  label:
+    project: aide
+spec: {}
+`,
+			wantErr: `6:3: metadata.label: unknown field "label"`,
+		},
+		{
+			name: "case 3: unknown prompt type and missing field",
+			data: `
+apiVersion: v1
+kind: Pipeline
+metadata: {}
+spec:
+  prompts:
+    - name: a
+      type: Inptu
+`,
+			wantErr: "4:11: metadata.name: required field is missing\n" +
+				`8:13: spec.prompts[0].type: unknown value "Inptu", expect one of Input, Text, Password, Confirm, Select, MultiSelect, Number, Path, Editor, Version`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePipeline([]byte(tt.data), nil, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ParsePipeline() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParsePipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePipeline_marshaled(t *testing.T) {
+	example, err := LoadPipelineFile("examples/file/pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPipeline("marshaled")
+	p.AddPathPrompt("dir", "Install dir?", "/opt", "", "")
+	p.AddPathPrompt("config", "Config file?", "", PathMustExist, "")
+	p.AddMultiSelectPrompt("components", "Components?", []string{"api", "web"}, []string{"api"}, "")
+	p.AddVar("bin", "{{ .env.dir }}/bin")
+	p.AddScriptStep("install", "bash", "echo $dir")
+	p.AddUsesStep("write", "test-write-file", map[string]interface{}{"path": "out", "mode": 420})
+
+	for _, pipeline := range []*Pipeline{example, p} {
+		b, err := yaml.Marshal(pipeline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParsePipeline(b, nil, "")
+		if err != nil {
+			t.Fatalf("ParsePipeline() of the marshaled %s error = %v", pipeline.Metadata.Name, err)
+		}
+		again, err := yaml.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(b) {
+			t.Errorf("marshaled %s again = %s, want %s", pipeline.Metadata.Name, again, b)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/99nil/gopkg/sets"
+)
+
+const redactedValue = "******"
+
+// secretNames returns the names of the secret prompts and vars.
+func (p *Pipeline) secretNames() sets.String {
+	names := sets.NewString()
+	for k := range p.Spec.Prompts {
+		if p.Spec.Prompts[k].IsSecret() {
+			names.Add(p.Spec.Prompts[k].Name)
+		}
+	}
+	for _, v := range p.Spec.Vars {
+		if v.Secret {
+			names.Add(v.Name)
+		}
+	}
+	return names
+}
+
+// buildRedactor builds the redactor with the answers of the secret prompts.
+func (p *Pipeline) buildRedactor(envSet map[string]string) {
+	var pairs []string
+	for _, name := range p.secretNames().List() {
+		if value := envSet[name]; len(value) > 0 {
+			pairs = append(pairs, value, redactedValue)
+		}
+	}
+	if len(pairs) == 0 {
+		p.redactor = nil
+		return
+	}
+	p.redactor = strings.NewReplacer(pairs...)
+}
+
+// redact replaces the secret values in s.
+func (p *Pipeline) redact(s string) string {
+	if p.redactor == nil {
+		return s
+	}
+	return p.redactor.Replace(s)
+}
+
+// redactError returns an error with the secret values in the message of err redacted.
+func (p *Pipeline) redactError(err error) error {
+	if err == nil || p.redactor == nil {
+		return err
+	}
+	msg := p.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// commandEnv returns the variables exported to the command of the step,
+// the secrets are excluded unless the step declares them.
+func (p *Pipeline) commandEnv(envSet map[string]string, step *SpecStep) map[string]string {
+	secrets := p.secretNames()
+	if secrets.Len() == 0 {
+		return envSet
+	}
+	exported := sets.NewString(step.Secrets...)
+	env := make(map[string]string, len(envSet))
+	for k, v := range envSet {
+		if secrets.Has(k) && !exported.Has(k) {
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// newMaskWriter returns a writer which masks the secret values written to w.
+// The output is buffered by lines so that the secrets split across writes are masked too,
+// Flush must be called to write the remaining data.
+func (p *Pipeline) newMaskWriter(w io.Writer) *maskWriter {
+	return &maskWriter{w: w, redact: p.redact}
+}
+
+// maskWriter masks the secret values line by line.
+type maskWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	buf    bytes.Buffer
+	redact func(string) string
+}
+
+func (m *maskWriter) Write(b []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buf.Write(b)
+	if i := bytes.LastIndexByte(m.buf.Bytes(), '\n'); i >= 0 {
+		line := m.buf.Next(i + 1)
+		if _, err := io.WriteString(m.w, m.redact(string(line))); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes the remaining data which does not end with a newline.
+func (m *maskWriter) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buf.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(m.w, m.redact(m.buf.String()))
+	m.buf.Reset()
+	return err
+}
+
+var _ ContextLogInterface = (*redactLog)(nil)
+
+// redactLog redacts the secret values in the logs written by the executors of the steps,
+// which are unaware of the secrets. The output written to its Writer is logged line by line,
+// Flush must be called when the step finishes to log the remaining output.
+type redactLog struct {
+	LogInterface
+	redact  func(string) string
+	writers *redactWriters
+}
+
+// redactWriters are the writers returned by the redactLog and its copies bound to the contexts.
+type redactWriters struct {
+	mu sync.Mutex
+	ws []*lineWriter
+}
+
+func newRedactLog(logger LogInterface, redact func(string) string) *redactLog {
+	return &redactLog{LogInterface: logger, redact: redact, writers: &redactWriters{}}
+}
+
+func (l *redactLog) Log(level LogLevel, args ...interface{}) {
+	redacted := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		redacted = append(redacted, l.redact(fmt.Sprint(arg)))
+	}
+	l.LogInterface.Log(level, redacted...)
+}
+
+func (l *redactLog) Logf(level LogLevel, format string, args ...interface{}) {
+	l.LogInterface.Logf(level, "%s", l.redact(fmt.Sprintf(format, args...)))
+}
+
+func (l *redactLog) Writer() io.Writer {
+	w := &lineWriter{log: func(line string) { l.Log(InfoLevel, line) }}
+	l.writers.mu.Lock()
+	defer l.writers.mu.Unlock()
+	l.writers.ws = append(l.writers.ws, w)
+	return w
+}
+
+// Flush logs the remaining output written to the writers which does not end with a newline.
+func (l *redactLog) Flush() error {
+	l.writers.mu.Lock()
+	defer l.writers.mu.Unlock()
+
+	for _, w := range l.writers.ws {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	l.writers.ws = nil
+	return nil
+}
+
+// WithContext keeps the redaction when the logger is bound to the context of the nested steps.
+func (l *redactLog) WithContext(ctx context.Context) LogInterface {
+	return &redactLog{LogInterface: withContext(l.LogInterface, ctx), redact: l.redact, writers: l.writers}
+}
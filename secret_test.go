@@ -0,0 +1,101 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_secrets(t *testing.T) {
+	p := NewPipeline("test")
+	p.AddPasswordPrompt("pwd", "Password", "")
+	p.AddInputPrompt("token", "Token", "", "")
+	p.Spec.Prompts[1].Secret = true
+	p.AddInputPrompt("name", "Name", "", "")
+	p.AddStep("", nil, "echo $pwd")
+	p.Spec.Steps[0].Secrets = []string{"pwd"}
+
+	envSet := map[string]string{"pwd": "p@ss", "token": "t0ken", "name": "aide"}
+	p.buildRedactor(envSet)
+
+	env := p.commandEnv(envSet, &p.Spec.Steps[0])
+	if _, ok := env["token"]; ok {
+		t.Errorf("commandEnv() should not export the undeclared secret")
+	}
+	if env["pwd"] != "p@ss" || env["name"] != "aide" {
+		t.Errorf("commandEnv() = %v", env)
+	}
+
+	// The env of the step cannot pass the undeclared secret to the command either.
+	p.Spec.Steps[0].Env = map[string]string{"PWD_COPY": "{{ .env.pwd }}", "TOKEN_COPY": "{{ .env.token }}"}
+	env, err := p.stepEnv(envSet, &p.Spec.Steps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["PWD_COPY"] != "p@ss" || env["TOKEN_COPY"] != "" {
+		t.Errorf("stepEnv() PWD_COPY = %q, TOKEN_COPY = %q", env["PWD_COPY"], env["TOKEN_COPY"])
+	}
+	var warnings []string
+	for _, e := range p.Lint() {
+		if strings.Contains(e.Error(), "secret") {
+			warnings = append(warnings, e.Error())
+		}
+	}
+	want := []string{`spec.steps[0].env.TOKEN_COPY: warning: secret "token" is not exported to the command, declare it in secrets`}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("Lint() = %v, want %v", warnings, want)
+	}
+
+	var buf bytes.Buffer
+	w := p.newMaskWriter(&buf)
+	for _, s := range []string{"pwd=p@", "ss\ntoken=t0", "ken name=aide"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "pwd=******\ntoken=****** name=aide"; buf.String() != want {
+		t.Errorf("maskWriter got %q, want %q", buf.String(), want)
+	}
+
+	// The logs of the step types and plugins are redacted too.
+	var logs strings.Builder
+	p.SetLogger(NewJSONLog(&logs))
+	logger := p.executorLogger(context.Background(), stepRun{name: "plugin", num: 1})
+	logger.Log(InfoLevel, "login with ", "p@ss")
+	logger.Logf(WarnLevel, "token %s", "t0ken")
+	_, _ = io.WriteString(logger.WithContext(context.Background()).Writer(), "output p@ss\nlast t0ken")
+	if err := logger.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "p@ss") || strings.Contains(logs.String(), "t0ken") ||
+		strings.Count(logs.String(), redactedValue) != 4 || !strings.Contains(logs.String(), "last "+redactedValue) {
+		t.Errorf("executorLogger() logs = %s", logs.String())
+	}
+
+	origin := errors.New("login with t0ken failed")
+	err = p.redactError(origin)
+	if err.Error() != "login with ****** failed" || !errors.Is(err, origin) {
+		t.Errorf("redactError() = %v", err)
+	}
+}
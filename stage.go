@@ -26,6 +26,10 @@ import (
 
 type Stage struct {
 	logger LogInterface
+	// owner is the Instance which the stage is added to.
+	owner *Instance
+	index int
+	steps []*Step
 
 	instance *stage.Instance
 	preFunc  stage.InstanceFunc
@@ -79,15 +83,19 @@ func (s *Stage) AddSteps(steps ...*Step) *Stage {
 		s.total++
 		step.num = s.total
 		step.stage = s
+		s.steps = append(s.steps, step)
+		step := step
 		step.instance.SetPreFunc(func(sc stage.Context) error {
+			sc.WithValue(StepCtxKey, step.newContext(sc))
 			if len(s.symbol) == 0 {
 				return nil
 			}
+			logger := withContext(s.logger, sc)
 			if strings.Count(s.symbol, "%s") > 0 {
 				name := stage.ContextName(sc)
-				s.logger.Logf(Unknown, s.symbol, name)
+				logger.Logf(Unknown, s.symbol, name)
 			} else {
-				s.logger.Logf(Unknown, s.symbol)
+				logger.Logf(Unknown, s.symbol)
 			}
 			return nil
 		})
@@ -0,0 +1,233 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Journal defines the persisted state of a pipeline run, which is used to resume the run.
+type Journal struct {
+	Pipeline string `json:"pipeline"`
+	// Hash is the content hash of the metadata and the prompts of the pipeline.
+	Hash string `json:"hash"`
+	// Answers defines the answers of the prompts, the secrets are never persisted.
+	Answers map[string]string `json:"answers"`
+	// Steps defines the completed steps.
+	Steps []JournalStep `json:"steps"`
+	// Outputs defines the outputs registered by the completed steps.
+	Outputs   map[string]string `json:"outputs"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// JournalStep defines a completed step.
+type JournalStep struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// Hash is the content hash of the step.
+	Hash string `json:"hash"`
+}
+
+// DefaultStateDir returns the default directory to persist the run state.
+func DefaultStateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aide", "state")
+	}
+	return filepath.Join(home, ".aide", "state")
+}
+
+// SetStateDir sets the directory to persist the run state, the state is not persisted if empty.
+// It is also the default of the state-dir flag, so the state is persisted only if opted in.
+func (p *Pipeline) SetStateDir(dir string) {
+	p.stateDir = dir
+}
+
+// SetStateKey sets the key which identifies the source of the pipeline, e.g. the path of the file.
+// The journals of the pipelines with the same name from different sources are kept apart by the key.
+func (p *Pipeline) SetStateKey(key string) {
+	p.stateKey = key
+}
+
+// SetResume sets whether to resume the last failed run from the persisted state.
+func (p *Pipeline) SetResume(resume bool) {
+	p.resume = resume
+}
+
+func (p *Pipeline) journalPath() string {
+	name := p.Metadata.Name
+	if len(p.stateKey) > 0 {
+		name += "-" + contentHash(p.stateKey)[:12]
+	}
+	return filepath.Join(p.stateDir, name+".json")
+}
+
+func contentHash(v interface{}) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// promptsHash returns the content hash of the metadata and the prompts,
+// the defaults are excluded because they are overridden by the flags.
+func (p *Pipeline) promptsHash() string {
+	prompts := make([]SpecPrompt, 0, len(p.Spec.Prompts))
+	for _, prompt := range p.Spec.Prompts {
+		prompt.Default = ""
+		prompts = append(prompts, prompt)
+	}
+	return contentHash(struct {
+		Metadata Metadata
+		Prompts  []SpecPrompt
+	}{p.Metadata, prompts})
+}
+
+// startJournal loads the journal to resume from, or starts a new one.
+func (p *Pipeline) startJournal() error {
+	p.journal = nil
+	if len(p.stateDir) == 0 {
+		if p.resume {
+			return errors.New("the state dir is required to resume")
+		}
+		return nil
+	}
+	if !p.resume {
+		p.journal = &Journal{
+			Pipeline: p.Metadata.Name,
+			Hash:     p.promptsHash(),
+			Answers:  make(map[string]string),
+			Outputs:  make(map[string]string),
+		}
+		return nil
+	}
+
+	b, err := os.ReadFile(p.journalPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no run of pipeline %s to resume", p.Metadata.Name)
+		}
+		return err
+	}
+	journal := &Journal{}
+	if err := json.Unmarshal(b, journal); err != nil {
+		return fmt.Errorf("parse journal %s failed: %v", p.journalPath(), err)
+	}
+	if journal.Hash != p.promptsHash() {
+		return errors.New("cannot resume, the metadata or prompts of the pipeline have changed since the last run")
+	}
+	for _, step := range journal.Steps {
+		if step.Index >= len(p.Spec.Steps) || contentHash(p.Spec.Steps[step.Index]) != step.Hash {
+			return fmt.Errorf("cannot resume, the completed step[%d] of the pipeline has changed since the last run", step.Index)
+		}
+	}
+	if journal.Answers == nil {
+		journal.Answers = make(map[string]string)
+	}
+	if journal.Outputs == nil {
+		journal.Outputs = make(map[string]string)
+	}
+	p.journal = journal
+	return nil
+}
+
+// answered returns the answer of the prompt persisted in the journal to resume from.
+func (p *Pipeline) answered(name string) (string, bool) {
+	if p.journal == nil || !p.resume {
+		return "", false
+	}
+	answer, ok := p.journal.Answers[name]
+	return answer, ok
+}
+
+// completed returns whether the step is completed in the journal.
+func (p *Pipeline) completed(index int) bool {
+	if p.journal == nil {
+		return false
+	}
+	for _, step := range p.journal.Steps {
+		if step.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAnswers records the answers of the non-secret prompts into the journal.
+func (p *Pipeline) recordAnswers(envSet map[string]string) error {
+	if p.journal == nil {
+		return nil
+	}
+	secrets := p.secretNames()
+	for _, prompt := range p.Spec.Prompts {
+		if !secrets.Has(prompt.Name) {
+			p.journal.Answers[prompt.Name] = envSet[prompt.Name]
+		}
+	}
+	return p.saveJournal()
+}
+
+// recordStep records the completed step and its outputs into the journal.
+func (p *Pipeline) recordStep(index int, envSet map[string]string) error {
+	if p.journal == nil {
+		return nil
+	}
+	step := p.Spec.Steps[index]
+	for _, name := range step.Outputs() {
+		p.journal.Outputs[name] = envSet[name]
+	}
+	p.journal.Steps = append(p.journal.Steps, JournalStep{
+		Index: index,
+		Name:  step.Name,
+		Hash:  contentHash(step),
+	})
+	return p.saveJournal()
+}
+
+func (p *Pipeline) saveJournal() error {
+	p.journal.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(p.journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.stateDir, 0o700); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that the journal is not corrupted when interrupted.
+	tmp := p.journalPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.journalPath())
+}
+
+// finishJournal removes the journal after the run is completed.
+func (p *Pipeline) finishJournal() error {
+	if p.journal == nil {
+		return nil
+	}
+	p.journal = nil
+	if err := os.Remove(p.journalPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_resume(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+
+	newPipeline := func(second string) *Pipeline {
+		p := NewPipeline("test")
+		p.skipPrompt = true
+		p.SetStateDir(dir)
+		p.AddInputPrompt("name", "Name", "aide", "")
+		p.AddStep("first", nil, "echo $name >> "+marker)
+		p.AddStep("second", nil, second)
+		return p
+	}
+
+	p := newPipeline("exit 1")
+	if err := p.Execute(context.Background()); err == nil {
+		t.Fatal("Execute() should fail")
+	}
+	if _, err := os.Stat(p.journalPath()); err != nil {
+		t.Fatalf("journal should be persisted: %v", err)
+	}
+
+	// The completed step is changed.
+	p = newPipeline("true")
+	p.Spec.Steps[0].Name = "changed"
+	p.SetResume(true)
+	if err := p.Execute(context.Background()); err == nil {
+		t.Fatal("Execute() should refuse to resume")
+	}
+
+	p = newPipeline("true")
+	p.Spec.Prompts[0].Default = "ignored"
+	p.SetResume(true)
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	b, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "aide\n" {
+		t.Errorf("the completed step should run only once, got %q", string(b))
+	}
+	if _, err := os.Stat(p.journalPath()); !os.IsNotExist(err) {
+		t.Errorf("journal should be removed after completion")
+	}
+}
+
+func TestPipeline_journalPath(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPipeline("test")
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	p.BindFlags(set)
+	if err := set.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.stateDir != "" {
+		t.Errorf("the state should not be persisted by default, got state dir %s", p.stateDir)
+	}
+
+	p.SetStateDir(dir)
+	if got, want := p.journalPath(), filepath.Join(dir, "test.json"); got != want {
+		t.Errorf("journalPath() = %s, want %s", got, want)
+	}
+	p.SetStateKey("/opt/a/pipeline.yaml")
+	a := p.journalPath()
+	p.SetStateKey("/opt/b/pipeline.yaml")
+	b := p.journalPath()
+	if a == b || filepath.Dir(a) != dir || !strings.HasPrefix(filepath.Base(a), "test-") {
+		t.Errorf("journalPath() = %s and %s, want different journals of test under %s", a, b, dir)
+	}
+}
@@ -45,13 +45,28 @@ func (s *Step) SetFunc(srf StepFunc) *Step {
 	return s
 }
 
+// reporter returns the reporter of the Instance which the step belongs to.
+func (s *Step) reporter() *reporter {
+	if s.stage == nil || s.stage.owner == nil {
+		return nil
+	}
+	return s.stage.owner.reporter
+}
+
 func (s *Step) execute(sc stage.Context) error {
+	r := s.reporter()
+	r.startStep(s.stage.index, s.num)
+
+	err := s.executeContext(sc)
+	r.finishStep(s.stage.index, s.num, err)
+	return err
+}
+
+func (s *Step) executeContext(sc stage.Context) error {
 	stepCtx, ok := sc.Value(StepCtxKey).(*StepContext)
-	if !ok {
-		stepCtx = &StepContext{
-			ctx:    sc,
-			logger: s.stage.logger,
-		}
+	if !ok || stepCtx == nil {
+		stepCtx = s.newContext(sc)
+		sc.WithValue(StepCtxKey, stepCtx)
 	}
 	s.run(stepCtx)
 
@@ -66,12 +81,27 @@ func (s *Step) execute(sc stage.Context) error {
 			level = ErrorLevel
 		}
 		if stepCtx.err != stage.ErrStageEnd {
-			s.stage.logger.Logf(level, "%s", standardMessage(stepCtx.err.Error()))
+			stepCtx.logger.Logf(level, "%s", standardMessage(stepCtx.err.Error()))
 		}
 	}
 	return stepCtx.err
 }
 
+// newContext returns the StepContext of the step, its logger is bound to sc.
+func (s *Step) newContext(sc stage.Context) *StepContext {
+	stepCtx := &StepContext{
+		ctx:       sc,
+		stageName: s.stage.name,
+		stepName:  s.name,
+		num:       s.num,
+	}
+	// The logger can find the step context by StepCtxKey.
+	ctx := stage.NewCtx(sc)
+	ctx.WithValue(StepCtxKey, stepCtx)
+	stepCtx.logger = withContext(s.stage.logger, ctx)
+	return stepCtx
+}
+
 func (s *Step) run(sc *StepContext) {
 	if s.srf == nil {
 		return
@@ -107,6 +137,10 @@ type StepContext struct {
 	ctx    stage.Context
 	logger LogInterface
 
+	stageName string
+	stepName  string
+	num       int
+
 	level LogLevel
 	// err defines the error when an exception exits.
 	err error
@@ -150,6 +184,21 @@ func (c *StepContext) Exit() {
 	panic(c)
 }
 
+// StageName returns the name of the stage which the step belongs to.
+func (c *StepContext) StageName() string {
+	return c.stageName
+}
+
+// StepName returns the name of the step.
+func (c *StepContext) StepName() string {
+	return c.stepName
+}
+
+// StepNum returns the number of the step in the stage, starting from 1.
+func (c *StepContext) StepNum() int {
+	return c.num
+}
+
 // Context returns a stage.Context
 func (c *StepContext) Context() stage.Context {
 	return c.ctx
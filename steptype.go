@@ -0,0 +1,224 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StepExecutor executes the steps of a type registered by RegisterStepType.
+// The params in `with` of the step are decoded into the executor before Execute,
+// so the executor is usually a pointer to a struct with yaml tags.
+type StepExecutor interface {
+	Execute(ctx context.Context, in *StepInput) error
+}
+
+// StepFactory returns a new StepExecutor for each execution of the step.
+type StepFactory func() StepExecutor
+
+// StepOutputter can be implemented by the StepExecutor to declare the names of its outputs,
+// so that the references to them are known by Lint.
+type StepOutputter interface {
+	Outputs() []string
+}
+
+// StepInput defines the input of the StepExecutor.
+type StepInput struct {
+	// Name is the name of the step.
+	Name string
+	// Vars defines the variables of the pipeline, including the answers, labels and outputs.
+	// The secrets are excluded unless the step declares them.
+	Vars map[string]string
+	// Logger writes the logs of the step.
+	Logger LogInterface
+	// Pipeline is the metadata of the pipeline.
+	Pipeline Metadata
+
+	outputs map[string]string
+	// run is the name of the run of the step, fsys is the filesystem of the files of the step,
+	// and parent is the pipeline of the step, they are used by the built-in step types.
+	run    string
+	fsys   fs.FS
+	parent *Pipeline
+	// env is the environment of the plugin process, built like the commands.
+	env map[string]string
+}
+
+// SetOutput registers the output of the step, it is available as `<step name>_<name>`
+// in the subsequent steps. The outputs of the unnamed steps are ignored.
+func (in *StepInput) SetOutput(name, value string) {
+	if in.outputs == nil {
+		in.outputs = make(map[string]string)
+	}
+	in.outputs[name] = value
+}
+
+var stepTypes = struct {
+	sync.RWMutex
+	factories map[string]StepFactory
+}{factories: make(map[string]StepFactory)}
+
+// RegisterStepType registers the step type referenced by `uses: name` in the pipeline.
+// It panics if the name is invalid, the factory is nil, or the name is registered twice.
+func RegisterStepType(name string, factory StepFactory) {
+	if err := ValidateName(name); err != nil {
+		panic(fmt.Sprintf("aide: register step type %q failed: %v", name, err))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("aide: register step type %q failed: factory is nil", name))
+	}
+
+	stepTypes.Lock()
+	defer stepTypes.Unlock()
+	if _, ok := stepTypes.factories[name]; ok {
+		panic(fmt.Sprintf("aide: step type %q is registered twice", name))
+	}
+	stepTypes.factories[name] = factory
+}
+
+// StepTypes returns the sorted names of the registered step types.
+func StepTypes() []string {
+	stepTypes.RLock()
+	defer stepTypes.RUnlock()
+	names := make([]string, 0, len(stepTypes.factories))
+	for name := range stepTypes.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupStepType(name string) (StepFactory, bool) {
+	stepTypes.RLock()
+	defer stepTypes.RUnlock()
+	factory, ok := stepTypes.factories[name]
+	return factory, ok
+}
+
+// executeUses executes the step of the registered type, and registers its outputs.
+func (p *Pipeline) executeUses(ctx context.Context, envSet map[string]string, run stepRun) error {
+	step := &p.Spec.Steps[run.index]
+	factory, err := p.stepFactory(step.Uses)
+	if err != nil {
+		return err
+	}
+	executor := factory()
+	if err := decodeStepParams(envSet, step.With, executor); err != nil {
+		return fmt.Errorf("decode params of step type %s failed: %v", step.Uses, err)
+	}
+
+	env, err := p.stepEnv(envSet, step)
+	if err != nil {
+		return err
+	}
+	logger := p.executorLogger(ctx, run)
+	in := &StepInput{
+		Name:     step.Name,
+		Vars:     p.commandEnv(envSet, step),
+		Logger:   logger,
+		Pipeline: p.Metadata,
+		run:      run.name,
+		fsys:     step.fsys,
+		parent:   p,
+		env:      env,
+	}
+	err = executor.Execute(ctx, in)
+	_ = logger.Flush()
+	if err != nil {
+		return fmt.Errorf("run step type %s failed: %w", step.Uses, err)
+	}
+	if len(step.Name) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, value := range in.outputs {
+		envSet[step.Name+"_"+name] = value
+		if !containsString(step.outputs, step.Name+"_"+name) {
+			step.outputs = append(step.outputs, step.Name+"_"+name)
+		}
+	}
+	return nil
+}
+
+// executorLogger returns the logger of the registered step types and plugins,
+// the secret values in their logs are redacted.
+func (p *Pipeline) executorLogger(ctx context.Context, run stepRun) *redactLog {
+	var logger LogInterface
+	switch {
+	case p.logger != nil:
+		logger = p.stepLogger(ctx, run)
+	case p.progress != nil:
+		logger = newProgressLog(p.progress, run.num)
+	default:
+		logger = newLog(true)
+	}
+	return newRedactLog(logger, p.redact)
+}
+
+// decodeStepParams renders the templates in the string values of params as plain text,
+// and decodes them into v strictly, the unknown params are rejected.
+func decodeStepParams(envSet map[string]string, params map[string]interface{}, v interface{}) error {
+	rendered, err := renderParams(envSet, params)
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		rendered = map[string]interface{}{}
+	}
+	b, err := yaml.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	return decoder.Decode(v)
+}
+
+func renderParams(envSet map[string]string, value interface{}) (interface{}, error) {
+	switch val := value.(type) {
+	case string:
+		return renderText(envSet, "with", val)
+	case []interface{}:
+		out := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			v, err := renderParams(envSet, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			v, err := renderParams(envSet, item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			out[k] = v
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type writeFileStep struct {
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	Mode    int    `yaml:"mode"`
+}
+
+func (s *writeFileStep) Execute(_ context.Context, in *StepInput) error {
+	in.Logger.Log(InfoLevel, "write ", s.Path)
+	in.SetOutput("path", s.Path)
+	return os.WriteFile(s.Path, []byte(s.Content+in.Vars["suffix"]), os.FileMode(s.Mode))
+}
+
+func (s *writeFileStep) Outputs() []string {
+	return []string{"path"}
+}
+
+func init() {
+	RegisterStepType("test-write-file", func() StepExecutor { return &writeFileStep{} })
+}
+
+func TestPipeline_uses(t *testing.T) {
+	dir := t.TempDir()
+	data := `
+apiVersion: v1
+kind: Pipeline
+metadata:
+  name: uses
+  labels:
+    suffix: "!"
+spec:
+  prompts:
+    - name: who
+      type: Input
+      default: aide
+  steps:
+    - name: write
+      uses: test-write-file
+      with:
+        path: ` + filepath.Join(dir, "out.txt") + `
+        content: "hello {{ .env.who }}"
+        mode: 0644
+    - name: check
+      command: test "$write_path" = "` + filepath.Join(dir, "out.txt") + `"
+`
+	p, err := ParsePipeline([]byte(data), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p.skipPrompt = true
+	p.SetStateDir(t.TempDir())
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello aide!" {
+		t.Errorf("content = %q, want %q", b, "hello aide!")
+	}
+
+	tests := []struct {
+		name string
+		step SpecStep
+		want string
+	}{
+		{
+			name: "unknown type",
+			step: SpecStep{Name: "x", Uses: "missing"},
+			want: `spec.steps[0].uses: unknown step type "missing"`,
+		},
+		{
+			name: "with without uses",
+			step: SpecStep{Name: "x", Command: new(string), With: map[string]interface{}{"a": 1}},
+			want: "spec.steps[0].with: with is only allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPipeline("uses")
+			p.Spec.Steps = []SpecStep{tt.step}
+			err := p.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	p = NewPipeline("uses")
+	p.AddUsesStep("write", "test-write-file", map[string]interface{}{"unknown": true})
+	p.SetStateDir(t.TempDir())
+	p.SetLogger(NewJSONLog(&strings.Builder{}))
+	if err := p.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "field unknown not found") {
+		t.Errorf("Execute() error = %v, want unknown param error", err)
+	}
+}
+
+func TestDecodeStepParams(t *testing.T) {
+	envSet := map[string]string{"who": "O'Brien & <Co>"}
+	params := map[string]interface{}{
+		"path":    "/tmp/{{ .env.who }}",
+		"content": "hello {{ .env.who }}",
+	}
+	var step writeFileStep
+	if err := decodeStepParams(envSet, params, &step); err != nil {
+		t.Fatal(err)
+	}
+	if step.Path != "/tmp/O'Brien & <Co>" || step.Content != "hello O'Brien & <Co>" {
+		t.Errorf("decoded params = %+v, want the values unescaped", step)
+	}
+}
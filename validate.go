@@ -0,0 +1,347 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validate collects all the problems of the pipeline,
+// the path of each error refers to the field in the configuration.
+func (p *Pipeline) validate() FieldErrors {
+	var errs FieldErrors
+	addErr := func(path, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.Kind != PipelineKind {
+		addErr("kind", "expect %s, not %s", PipelineKind, p.Kind)
+	}
+	if err := ValidateName(p.Metadata.Name); err != nil {
+		addErr("metadata.name", "%v", err)
+	}
+
+	promptNames := make(map[string]int)
+	for k, prompt := range p.Spec.Prompts {
+		path := fmt.Sprintf("spec.prompts[%d]", k)
+		if err := ValidateName(prompt.Name); err != nil {
+			addErr(path+".name", "%v", err)
+		} else if i, ok := promptNames[prompt.Name]; ok {
+			addErr(path+".name", "duplicate prompt name %q, already defined in spec.prompts[%d]", prompt.Name, i)
+		} else {
+			promptNames[prompt.Name] = k
+		}
+
+		switch prompt.Type {
+		case PromptInput:
+		case PromptPassword:
+		case PromptText:
+		case PromptConfirm:
+			if len(prompt.Default) > 0 && !isTemplate(prompt.Default) {
+				if _, err := strconv.ParseBool(prompt.Default); err != nil {
+					addErr(path+".default", "confirm default must be a boolean, not %q", prompt.Default)
+				}
+			}
+		case PromptSelect, PromptMultiSelect:
+			if from := prompt.EnumFrom; from != nil {
+				if (len(from.Command) > 0) == (len(from.File) > 0) {
+					addErr(path+".enumFrom", "exactly one of command and file must be defined")
+				}
+			} else if len(prompt.Enum) == 0 {
+				addErr(path+".enum", "select requires at least one enum or enumFrom")
+				break
+			}
+			// The templated or dynamic options can only be checked when asked.
+			if len(prompt.Default) == 0 || isTemplate(prompt.Default) || isTemplate(prompt.Enum...) || prompt.EnumFrom != nil {
+				break
+			}
+			defaults := []string{prompt.Default}
+			if prompt.Type == PromptMultiSelect {
+				defaults = strings.Split(prompt.Default, ",")
+			}
+			for _, v := range defaults {
+				if !containsString(prompt.Enum, v) {
+					addErr(path+".default", "default %q is not one of the enum", v)
+				}
+			}
+		case PromptNumber:
+			if prompt.Min != nil && prompt.Max != nil && *prompt.Min > *prompt.Max {
+				addErr(path+".min", "min %s is greater than max %s", formatNumber(*prompt.Min), formatNumber(*prompt.Max))
+			}
+		case PromptPath:
+			if len(prompt.PathCheck) > 0 && !containsString(pathCheckNames(), string(prompt.PathCheck)) {
+				addErr(path+".pathCheck", "unknown path check(%s)", prompt.PathCheck)
+			}
+		case PromptEditor:
+		case PromptVersion:
+		default:
+			addErr(path+".type", "unknown prompt type(%s)", prompt.Type)
+		}
+		// The path is checked when asked since it depends on the host, so are the options.
+		if prompt.Type != PromptPath && prompt.Type != PromptSelect && prompt.Type != PromptMultiSelect &&
+			!isTemplate(prompt.Default) {
+			if _, err := prompt.normalize(prompt.Default); err != nil {
+				addErr(path+".default", "%v", err)
+			}
+		}
+		if prompt.Type != PromptNumber {
+			if prompt.Min != nil {
+				addErr(path+".min", "min is only allowed for %s", PromptNumber)
+			}
+			if prompt.Max != nil {
+				addErr(path+".max", "max is only allowed for %s", PromptNumber)
+			}
+			if prompt.Integer {
+				addErr(path+".integer", "integer is only allowed for %s", PromptNumber)
+			}
+		}
+		if prompt.Type != PromptPath && len(prompt.PathCheck) > 0 {
+			addErr(path+".pathCheck", "pathCheck is only allowed for %s", PromptPath)
+		}
+		if prompt.Type != PromptSelect && prompt.Type != PromptMultiSelect && prompt.EnumFrom != nil {
+			addErr(path+".enumFrom", "enumFrom is only allowed for %s and %s", PromptSelect, PromptMultiSelect)
+		}
+		if prompt.Type != PromptSelect && prompt.Type != PromptMultiSelect && len(prompt.EnumLabels) > 0 {
+			addErr(path+".enumLabels", "enumLabels is only allowed for %s and %s", PromptSelect, PromptMultiSelect)
+		} else if prompt.EnumFrom == nil && !isTemplate(prompt.Enum...) {
+			var unknown []string
+			for value := range prompt.EnumLabels {
+				if !containsString(prompt.Enum, value) {
+					unknown = append(unknown, value)
+				}
+			}
+			sort.Strings(unknown)
+			for _, value := range unknown {
+				addErr(path+".enumLabels."+value, "%q is not one of the enum", value)
+			}
+		}
+	}
+
+	varNames := make(map[string]int)
+	for k, v := range p.Spec.Vars {
+		path := fmt.Sprintf("spec.vars[%d]", k)
+		if err := ValidateName(v.Name); err != nil {
+			addErr(path+".name", "%v", err)
+		} else if i, ok := promptNames[v.Name]; ok {
+			addErr(path+".name", "var %q conflicts with the prompt defined in spec.prompts[%d]", v.Name, i)
+		} else if i, ok := varNames[v.Name]; ok {
+			addErr(path+".name", "duplicate var name %q, already defined in spec.vars[%d]", v.Name, i)
+		} else {
+			varNames[v.Name] = k
+		}
+	}
+
+	if len(p.Spec.Steps) == 0 {
+		addErr("spec.steps", "at least one step must be defined")
+	}
+	secrets := p.secretNames()
+	stepNames := make(map[string]int)
+	p.Spec.Inherit.validate("spec.inherit", addErr)
+	for k, step := range p.Spec.Steps {
+		path := fmt.Sprintf("spec.steps[%d]", k)
+		if step.Render == nil && !step.runsScript() && len(step.Uses) == 0 {
+			addErr(path, "step render, command, script, scriptFile or uses must be defined")
+		}
+		scripts := 0
+		for _, defined := range []bool{step.Command != nil, len(step.Script) > 0, len(step.ScriptFile) > 0} {
+			if defined {
+				scripts++
+			}
+		}
+		if scripts > 1 {
+			addErr(path, "only one of command, script and scriptFile can be defined")
+		}
+		if len(step.Uses) > 0 {
+			if _, err := p.stepFactory(step.Uses); err != nil {
+				addErr(path+".uses", "%v", err)
+			}
+			if file, ok := step.With["file"].(string); ok && step.Uses == PipelineStepType && !isTemplate(file) {
+				if _, err := loadNestedPipeline(step.fsys, file); err != nil {
+					addErr(path+".with.file", "load pipeline failed: %v", err)
+				}
+			}
+		} else if len(step.With) > 0 {
+			addErr(path+".with", "with is only allowed for the step which uses a step type")
+		}
+		if step.Render != nil || len(step.Name) > 0 {
+			if err := ValidateName(step.Name); err != nil {
+				addErr(path+".name", "%v", err)
+			} else if i, ok := stepNames[step.Name]; ok {
+				addErr(path+".name", "duplicate step name %q, already defined in spec.steps[%d]", step.Name, i)
+			} else {
+				stepNames[step.Name] = k
+			}
+		}
+		if !step.runsScript() && (step.Inherit != nil || len(step.Env) > 0 ||
+			len(step.Shell) > 0 || step.Strict != nil || len(step.Workdir) > 0) {
+			addErr(path, "inherit, env, shell, strict and workdir are only allowed for the step which runs a command or script")
+		}
+		step.Inherit.validate(path+".inherit", addErr)
+		if step.ForEach != nil && len(step.ForEach.Items) == 0 && !EnvNameRegexp.MatchString(step.ForEach.Var) {
+			addErr(path+".forEach", "forEach must be the name of a variable or a list of items")
+		}
+		if step.Parallel < 0 {
+			addErr(path+".parallel", "parallel must not be negative")
+		} else if step.Parallel > 0 && step.ForEach == nil {
+			addErr(path+".parallel", "parallel is only allowed for the step with forEach")
+		}
+		for name := range step.Env {
+			if !EnvNameRegexp.MatchString(name) {
+				addErr(path+".env."+name, "invalid environment variable name %q", name)
+			}
+		}
+		for i, name := range step.Secrets {
+			if !secrets.Has(name) {
+				addErr(fmt.Sprintf("%s.secrets[%d]", path, i), "%q is not a secret prompt", name)
+			}
+		}
+		if step.Render != nil {
+			var err error
+			if step.Render.fsys != nil {
+				_, err = fs.Stat(step.Render.fsys, step.Render.Src)
+			} else {
+				_, err = os.Stat(step.Render.Src)
+			}
+			if err != nil {
+				addErr(path+".render.src", "render source not found: %v", err)
+			}
+		}
+		if len(step.ScriptFile) > 0 {
+			var err error
+			if step.fsys != nil {
+				_, err = fs.Stat(step.fsys, step.ScriptFile)
+			} else {
+				_, err = os.Stat(step.ScriptFile)
+			}
+			if err != nil {
+				addErr(path+".scriptFile", "script file not found: %v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// ValidatePipeline parses data as ParsePipeline does, but collects all the problems
+// including the lint warnings in one pass instead of stopping at the first one.
+// The errors are located at the lines and columns of the fields in data.
+func ValidatePipeline(data []byte, fsys fs.FS, dir string) FieldErrors {
+	return checkPipeline(data, fsys, dir, func(p *Pipeline) FieldErrors {
+		return append(p.validate(), p.Lint()...)
+	})
+}
+
+// LintPipeline parses data as ParsePipeline does, and reports the lint problems
+// located at the lines and columns of the fields in data.
+func LintPipeline(data []byte, fsys fs.FS, dir string) FieldErrors {
+	return checkPipeline(data, fsys, dir, (*Pipeline).Lint)
+}
+
+func checkPipeline(data []byte, fsys fs.FS, dir string, check func(p *Pipeline) FieldErrors) FieldErrors {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return FieldErrors{yamlFieldError(err)}
+	}
+
+	errs := PipelineSchema().ValidateNode(&node, "")
+	var pipeline Pipeline
+	if err := node.Decode(&pipeline); err != nil {
+		if len(errs) == 0 {
+			errs = append(errs, yamlFieldError(err))
+		}
+		sortFieldErrors(errs)
+		return errs
+	}
+	pipeline.resolve(fsys, dir)
+
+	located := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		located[e.Path] = true
+	}
+	for _, e := range check(&pipeline) {
+		// The problem has been reported by the schema.
+		if located[e.Path] {
+			continue
+		}
+		if n := locateNode(&node, e.Path); n != nil {
+			e.Line, e.Column = n.Line, n.Column
+		}
+		errs = append(errs, e)
+	}
+	sortFieldErrors(errs)
+	return errs
+}
+
+var yamlLineRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func yamlFieldError(err error) *FieldError {
+	fe := &FieldError{Message: err.Error()}
+	if parts := yamlLineRegexp.FindStringSubmatch(err.Error()); len(parts) == 3 {
+		fe.Line, _ = strconv.Atoi(parts[1])
+		fe.Column = 1
+		fe.Message = parts[2]
+	}
+	return fe
+}
+
+var fieldPathRegexp = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)]`)
+
+// locateNode finds the node of the path like spec.steps[0].name,
+// it returns the deepest node found when the path does not fully exist.
+func locateNode(root *yaml.Node, path string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, parts := range fieldPathRegexp.FindAllStringSubmatch(path, -1) {
+		var next *yaml.Node
+		switch {
+		case len(parts[1]) > 0 && node.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == parts[1] {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case len(parts[2]) > 0 && node.Kind == yaml.SequenceNode:
+			i, _ := strconv.Atoi(parts[2])
+			if i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+// isTemplate reports whether any of texts contains templates.
+func isTemplate(texts ...string) bool {
+	for _, text := range texts {
+		if strings.Contains(text, "{{") {
+			return true
+		}
+	}
+	return false
+}
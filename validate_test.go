@@ -0,0 +1,63 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"testing"
+)
+
+func TestValidatePipeline(t *testing.T) {
+	data := `apiVersion: v1
+kind: Pipeline
+metadata:
+  name: test
+  label:
+    project: aide
+spec:
+  prompts:
+    - name: gender
+      type: Select
+      enum: [ "male", "female" ]
+      default: unknown
+    - name: gender
+      type: Input
+  steps:
+    - name: step1
+      command: echo $gender
+    - name: step1
+      command: echo $HOME
+    - name: config
+      render:
+        src: nope
+        dest: /tmp/nope
+`
+	want := []string{
+		`5:3: metadata.label: unknown field "label"`,
+		`12:16: spec.prompts[0].default: default "unknown" is not one of the enum`,
+		`13:13: spec.prompts[1].name: duplicate prompt name "gender", already defined in spec.prompts[0]`,
+		`18:13: spec.steps[1].name: duplicate step name "step1", already defined in spec.steps[0]`,
+		`22:14: spec.steps[2].render.src: render source not found: stat nope: no such file or directory`,
+	}
+
+	errs := ValidatePipeline([]byte(data), nil, "")
+	if len(errs) != len(want) {
+		t.Fatalf("ValidatePipeline() got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("ValidatePipeline() error[%d] = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"sync"
+	"text/template"
+)
+
+var (
+	hostOnce sync.Once
+	host     map[string]string
+)
+
+// hostFacts returns the facts of the host, they are available as `{{ .host.<name> }}` in the templates.
+func hostFacts() map[string]string {
+	hostOnce.Do(func() {
+		host = map[string]string{
+			"os":   runtime.GOOS,
+			"arch": runtime.GOARCH,
+			"cpus": strconv.Itoa(runtime.NumCPU()),
+		}
+		host["hostname"], _ = os.Hostname()
+		host["home"], _ = os.UserHomeDir()
+		if u, err := user.Current(); err == nil {
+			host["user"] = u.Username
+		}
+	})
+	return host
+}
+
+// evaluateVars evaluates the vars in order and sets them into envSet,
+// each var can reference the answers, labels, earlier vars and host facts.
+func (p *Pipeline) evaluateVars(envSet map[string]string) error {
+	for k, v := range p.Spec.Vars {
+		value, err := renderText(envSet, v.Name, v.Value)
+		if err != nil {
+			return fmt.Errorf("evaluate spec.vars[%d] %s failed: %v", k, v.Name, err)
+		}
+		envSet[v.Name] = value
+	}
+	return nil
+}
+
+// renderText renders text as a plain text template, the values are not HTML escaped.
+// The missing variables are rendered as empty strings like the other templates.
+func renderText(envSet map[string]string, name, text string) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, templateData(envSet)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
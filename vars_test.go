@@ -0,0 +1,77 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aide
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPipeline_vars(t *testing.T) {
+	p := NewPipeline("test")
+	p.Metadata.Labels = map[string]string{"db_host": "localhost"}
+	p.AddInputPrompt("install_dir", "Install dir?", "/opt/aide", "")
+	p.AddPasswordPrompt("db_password", "Database password?", "")
+	p.AddVar("bin_dir", "{{ .env.install_dir }}/bin")
+	p.AddVar("tool", "{{ .env.bin_dir }}/tool-{{ .host.os }}")
+	p.Spec.Vars = append(p.Spec.Vars, SpecVar{
+		Name:   "db_url",
+		Value:  "mysql://root:{{ .env.db_password }}@{{ .env.db_host }}/aide?a=1&b=2",
+		Secret: true,
+	})
+	p.AddVar("later", "{{ .env.future }}")
+	p.AddVar("future", "x")
+	p.AddStep("", nil, "echo $tool $db_url")
+	p.skipPrompt = true
+
+	envSet, err := p.prepare(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"bin_dir": "/opt/aide/bin",
+		"tool":    "/opt/aide/bin/tool-" + runtime.GOOS,
+		"db_url":  "mysql://root:@localhost/aide?a=1&b=2",
+		"later":   "",
+	}
+	for name, value := range want {
+		if envSet[name] != value {
+			t.Errorf("var %s = %q, want %q", name, envSet[name], value)
+		}
+	}
+	if !p.secretNames().Has("db_url") {
+		t.Error("db_url should be a secret")
+	}
+
+	var got []string
+	for _, e := range p.Lint() {
+		got = append(got, e.Error())
+	}
+	wantLint := []string{
+		`spec.vars[3].value: warning: undefined variable "future"`,
+		`spec.steps[0].command: warning: secret "db_url" is not exported to the command, declare it in secrets`,
+		`spec.vars[3].name: warning: var "later" is never used`,
+	}
+	if !reflect.DeepEqual(got, wantLint) {
+		t.Errorf("Lint() = %#v, want %#v", got, wantLint)
+	}
+
+	p.AddVar("install_dir", "x")
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() should reject the var which conflicts with a prompt")
+	}
+}